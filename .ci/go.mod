@@ -4,6 +4,7 @@ go 1.15
 
 require (
 	github.com/coreos/go-systemd v0.0.0-20191104093116-d3cd4ed1dbcf
+	github.com/go-logr/logr v1.2.4
 	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v1 v1.0.0-20140924161607-9f9df34309c0
@@ -0,0 +1,81 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"sort"
+	"strings"
+)
+
+// Fields is a set of key/value pairs attached to every entry of a child
+// logger. Structured formatters record them as fields, while text formatters
+// print them as "key=value" pairs ahead of the message.
+type Fields map[string]string
+
+// LogFields returns the fields themselves.
+func (f Fields) LogFields() map[string]string {
+	return f
+}
+
+// String renders the fields as "key=value" pairs sorted by key.
+func (f Fields) String() string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + f[k]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// WithFields returns a child logger that tags every entry with fields, on top
+// of any fields p already carries. The child is not registered with the repo:
+// it logs under p's package name and always uses the level of the package
+// logger it derives from, so later calls to SetLevel or SetLogLevel take
+// effect on existing children too. Children are cheap to create, e.g. one
+// per request or worker:
+//
+//	log := plog.WithFields(capnslog.Fields{"request": id})
+//	log.Infof("handling %s", r.URL)
+func (p *PackageLogger) WithFields(fields Fields) *PackageLogger {
+	root := p
+	if p.parent != nil {
+		root = p.parent
+	}
+	merged := make(Fields, len(p.fields)+len(fields))
+	for k, v := range p.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &PackageLogger{
+		repo:   root.repo,
+		pkg:    root.pkg,
+		parent: root,
+		fields: merged,
+	}
+}
+
+// levelOwner returns the logger whose level p follows.
+func (p *PackageLogger) levelOwner() *PackageLogger {
+	if p.parent != nil {
+		return p.parent
+	}
+	return p
+}
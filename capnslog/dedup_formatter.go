@@ -0,0 +1,75 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewDedupFormatter wraps a formatter so that a message identical to the
+// previous one (same package, level and text) is dropped if it arrives within
+// window of the last time that message was written. The number of dropped
+// copies is reported as "last message repeated N times" before the next
+// message that is written, whether a different one or a copy arriving after
+// the window, or when the formatter is flushed. No timer is involved, so the
+// report waits for one of those. This keeps a hot error loop from flooding
+// the log.
+func NewDedupFormatter(f Formatter, window time.Duration) Formatter {
+	return &dedupFormatter{
+		f:      f,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+type dedupFormatter struct {
+	f      Formatter
+	window time.Duration
+
+	pkg      string
+	level    LogLevel
+	msg      string
+	written  time.Time
+	repeated int
+
+	now func() time.Time
+}
+
+func (d *dedupFormatter) Format(pkg string, l LogLevel, depth int, entries ...interface{}) {
+	msg := fmt.Sprint(entries...)
+	now := d.now()
+	if pkg == d.pkg && l == d.level && msg == d.msg && now.Sub(d.written) < d.window {
+		d.repeated++
+		return
+	}
+	d.writeRepeated(depth + 1)
+	d.f.Format(pkg, l, depth+1, entries...)
+	d.pkg, d.level, d.msg = pkg, l, msg
+	d.written = now
+}
+
+func (d *dedupFormatter) writeRepeated(depth int) {
+	if d.repeated == 0 {
+		return
+	}
+	d.f.Format(d.pkg, d.level, depth+1, fmt.Sprintf("last message repeated %d times", d.repeated))
+	d.repeated = 0
+}
+
+func (d *dedupFormatter) Flush() {
+	d.writeRepeated(1)
+	d.f.Flush()
+}
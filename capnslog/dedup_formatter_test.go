@@ -0,0 +1,107 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupFormatter(t *testing.T) {
+	type entry struct {
+		after time.Duration
+		pkg   string
+		level LogLevel
+		msg   string
+	}
+	for i, tt := range []struct {
+		entries []entry
+		flush   bool
+		want    []string
+	}{
+		{
+			entries: []entry{
+				{0, "disk", ERROR, "write failed"},
+				{time.Millisecond, "disk", ERROR, "write failed"},
+				{time.Millisecond, "disk", ERROR, "write failed"},
+				{time.Millisecond, "disk", INFO, "recovered"},
+			},
+			want: []string{
+				"disk E write failed",
+				"disk E last message repeated 2 times",
+				"disk I recovered",
+			},
+		},
+		{
+			// Copies after the window are written, after the count.
+			entries: []entry{
+				{0, "disk", ERROR, "write failed"},
+				{time.Millisecond, "disk", ERROR, "write failed"},
+				{time.Second, "disk", ERROR, "write failed"},
+			},
+			want: []string{
+				"disk E write failed",
+				"disk E last message repeated 1 times",
+				"disk E write failed",
+			},
+		},
+		{
+			// Only the package, level and text together identify a copy.
+			entries: []entry{
+				{0, "disk", ERROR, "write failed"},
+				{0, "net", ERROR, "write failed"},
+				{0, "net", WARNING, "write failed"},
+			},
+			want: []string{
+				"disk E write failed",
+				"net E write failed",
+				"net W write failed",
+			},
+		},
+		{
+			entries: []entry{
+				{0, "disk", ERROR, "write failed"},
+				{time.Millisecond, "disk", ERROR, "write failed"},
+			},
+			flush: true,
+			want: []string{
+				"disk E write failed",
+				"disk E last message repeated 1 times",
+			},
+		},
+	} {
+		r := NewRingFormatter(10)
+		now := time.Unix(0, 0)
+		d := NewDedupFormatter(r, 500*time.Millisecond).(*dedupFormatter)
+		d.now = func() time.Time { return now }
+		for _, e := range tt.entries {
+			now = now.Add(e.after)
+			d.Format(e.pkg, e.level, 0, e.msg)
+		}
+		if tt.flush {
+			d.Flush()
+		}
+
+		var got []string
+		for _, e := range r.Entries() {
+			got = append(got, fmt.Sprintf("%s %s %s", e.Package, e.Level.Char(), e.Message))
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
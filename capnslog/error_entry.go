@@ -0,0 +1,104 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FieldsEntry is implemented by log entries that carry structured data. Text
+// formatters print entries with fmt as usual, while structured formatters,
+// such as the journald formatter, also record the fields.
+type FieldsEntry interface {
+	LogFields() map[string]string
+}
+
+// ErrorEntry is a log entry for an error, optionally with the stack of the
+// goroutine that logged it.
+type ErrorEntry struct {
+	Err   error
+	Stack []runtime.Frame
+}
+
+// Err returns a log entry for err.
+//
+//	plog.Error("failed to sync: ", capnslog.Err(err))
+func Err(err error) *ErrorEntry {
+	return &ErrorEntry{Err: err}
+}
+
+// ErrStack returns a log entry for err that includes the stack of the calling
+// goroutine.
+func ErrStack(err error) *ErrorEntry {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	e := &ErrorEntry{Err: err}
+	for {
+		f, more := frames.Next()
+		e.Stack = append(e.Stack, f)
+		if !more {
+			break
+		}
+	}
+	return e
+}
+
+// Chain returns the messages of the error and of every error it wraps, as
+// returned by errors.Unwrap, outermost first.
+func (e *ErrorEntry) Chain() []string {
+	var chain []string
+	for err := e.Err; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, err.Error())
+	}
+	return chain
+}
+
+// String renders the error message, followed by the innermost frame of the
+// stack if one was captured.
+func (e *ErrorEntry) String() string {
+	msg := "<nil>"
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if len(e.Stack) == 0 {
+		return msg
+	}
+	f := e.Stack[0]
+	return fmt.Sprintf("%s (at %s:%d)", msg, filepath.Base(f.File), f.Line)
+}
+
+// LogFields returns the error message, its chain of wrapped errors and, if
+// captured, the full stack.
+func (e *ErrorEntry) LogFields() map[string]string {
+	fields := map[string]string{
+		"ERROR": e.String(),
+	}
+	if chain := e.Chain(); len(chain) > 1 {
+		fields["ERROR_CHAIN"] = strings.Join(chain, "\n")
+	}
+	if len(e.Stack) > 0 {
+		var b strings.Builder
+		for _, f := range e.Stack {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		}
+		fields["STACK"] = b.String()
+	}
+	return fields
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorEntry(t *testing.T) {
+	base := errors.New("connection refused")
+	wrapped := fmt.Errorf("dial etcd: %w", base)
+
+	for i, tt := range []struct {
+		entry      *ErrorEntry
+		wantString string
+		wantChain  []string
+		wantFields map[string]string
+	}{
+		{
+			entry:      Err(base),
+			wantString: "connection refused",
+			wantChain:  []string{"connection refused"},
+			wantFields: map[string]string{"ERROR": "connection refused"},
+		},
+		{
+			entry:      Err(wrapped),
+			wantString: "dial etcd: connection refused",
+			wantChain:  []string{"dial etcd: connection refused", "connection refused"},
+			wantFields: map[string]string{
+				"ERROR":       "dial etcd: connection refused",
+				"ERROR_CHAIN": "dial etcd: connection refused\nconnection refused",
+			},
+		},
+		{
+			entry:      Err(nil),
+			wantString: "<nil>",
+			wantFields: map[string]string{"ERROR": "<nil>"},
+		},
+	} {
+		if got := tt.entry.String(); got != tt.wantString {
+			t.Errorf("case %d: String: want=%q got=%q", i, tt.wantString, got)
+		}
+		if got := tt.entry.Chain(); !reflect.DeepEqual(tt.wantChain, got) {
+			t.Errorf("case %d: Chain: want=%q got=%q", i, tt.wantChain, got)
+		}
+		if got := tt.entry.LogFields(); !reflect.DeepEqual(tt.wantFields, got) {
+			t.Errorf("case %d: LogFields: want=%q got=%q", i, tt.wantFields, got)
+		}
+	}
+}
+
+func TestErrStack(t *testing.T) {
+	line := nextLine()
+	e := ErrStack(errors.New("boom"))
+
+	if want := fmt.Sprintf("boom (at error_entry_test.go:%d)", line); e.String() != want {
+		t.Errorf("want=%q got=%q", want, e.String())
+	}
+	if f := e.Stack[0]; !strings.HasSuffix(f.Function, ".TestErrStack") {
+		t.Errorf("innermost frame is %s, want TestErrStack", f.Function)
+	}
+	stack := e.LogFields()["STACK"]
+	if want := fmt.Sprintf("error_entry_test.go:%d\n", line); !strings.Contains(stack, want) {
+		t.Errorf("STACK field missing %q:\n%s", want, stack)
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// FluentdConfig configures a FluentdFormatter. Zero values select the
+// defaults noted on each field.
+type FluentdConfig struct {
+	// Addr is the TCP address of the fluentd or fluent-bit forward input.
+	// Defaults to "127.0.0.1:24224".
+	Addr string
+	// Tag is the fluentd tag of every event. Defaults to the program name.
+	Tag string
+	// BufferSize is the maximum number of entries held while fluentd is
+	// unreachable. When it is exceeded the oldest entries are dropped.
+	// Defaults to 8192.
+	BufferSize int
+	// Timeout bounds dialing, writing and waiting for an ack. Defaults to
+	// 10 seconds.
+	Timeout time.Duration
+	// MaxBackoff is the longest wait between reconnection attempts.
+	// Defaults to 30 seconds.
+	MaxBackoff time.Duration
+	// FlushTimeout bounds how long Flush waits for buffered entries to be
+	// acknowledged. Defaults to 5 seconds.
+	FlushTimeout time.Duration
+}
+
+// FluentdFormatter ships entries to fluentd using the forward protocol. Each
+// event's record holds the message, level and package, plus the fields of any
+// FieldsEntry. Entries are buffered in memory and sent in batches from a
+// background goroutine, which reconnects with exponential backoff and resends
+// a batch until fluentd acknowledges it.
+type FluentdFormatter struct {
+	cfg FluentdConfig
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []fluentdEvent
+	sending int
+	dropped int
+	closed  bool
+
+	closing chan struct{}
+	done    chan struct{}
+}
+
+type fluentdEvent struct {
+	t      time.Time
+	record map[string]string
+}
+
+// NewFluentdFormatter returns a FluentdFormatter and starts its sender. The
+// connection is established lazily, so fluentd need not be running yet.
+func NewFluentdFormatter(cfg FluentdConfig) *FluentdFormatter {
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:24224"
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = "capnslog"
+		if len(os.Args) > 0 {
+			cfg.Tag = filepath.Base(os.Args[0])
+		}
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 8192
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = 5 * time.Second
+	}
+	f := &FluentdFormatter{
+		cfg:     cfg,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	f.cond = sync.NewCond(&f.mu)
+	go f.run()
+	return f
+}
+
+// blockingFlush marks FluentdFormatter as a blockingFlusher.
+func (f *FluentdFormatter) blockingFlush() {}
+
+func (f *FluentdFormatter) Format(pkg string, l LogLevel, _ int, entries ...interface{}) {
+	record := map[string]string{
+		"message": fmt.Sprint(entries...),
+		"level":   l.String(),
+		"package": pkg,
+	}
+	for _, entry := range entries {
+		if fe, ok := entry.(FieldsEntry); ok {
+			for k, v := range fe.LogFields() {
+				record[k] = v
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	if len(f.queue) >= f.cfg.BufferSize {
+		f.queue = f.queue[1:]
+		f.dropped++
+	}
+	f.queue = append(f.queue, fluentdEvent{time.Now(), record})
+	f.cond.Broadcast()
+}
+
+// Flush waits until every buffered entry has been acknowledged, or until
+// FlushTimeout has passed. PackageLogger.Flush and the Fatal methods call it
+// without holding the lock shared by all loggers, so waiting does not stall
+// them.
+func (f *FluentdFormatter) Flush() {
+	deadline := time.Now().Add(f.cfg.FlushTimeout)
+	timer := time.AfterFunc(f.cfg.FlushTimeout, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for (len(f.queue) > 0 || f.sending > 0) && !f.closed && time.Now().Before(deadline) {
+		f.cond.Wait()
+	}
+}
+
+// Dropped returns the number of entries discarded because the buffer was
+// full.
+func (f *FluentdFormatter) Dropped() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dropped
+}
+
+// Close flushes buffered entries and stops the sender. Entries formatted
+// after Close are discarded.
+func (f *FluentdFormatter) Close() {
+	f.Flush()
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.closing)
+	}
+	f.cond.Broadcast()
+	f.mu.Unlock()
+	<-f.done
+}
+
+func (f *FluentdFormatter) run() {
+	defer close(f.done)
+
+	var (
+		conn    net.Conn
+		backoff time.Duration
+	)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		f.mu.Lock()
+		for len(f.queue) == 0 && !f.closed {
+			f.cond.Wait()
+		}
+		if f.closed {
+			f.mu.Unlock()
+			return
+		}
+		batch := f.queue
+		f.queue = nil
+		f.sending = len(batch)
+		f.mu.Unlock()
+
+		for {
+			err := f.send(&conn, batch)
+			if err == nil {
+				backoff = 0
+				break
+			}
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+			backoff = timeutil.ExpBackoff(backoff, f.cfg.MaxBackoff)
+			if backoff > f.cfg.MaxBackoff {
+				backoff = f.cfg.MaxBackoff
+			}
+			select {
+			case <-f.closing:
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		f.mu.Lock()
+		f.sending = 0
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	}
+}
+
+func (f *FluentdFormatter) send(conn *net.Conn, batch []fluentdEvent) error {
+	if *conn == nil {
+		c, err := net.DialTimeout("tcp", f.cfg.Addr, f.cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		*conn = c
+	}
+	c := *conn
+
+	chunk, err := newChunkID()
+	if err != nil {
+		return err
+	}
+	msg := encodeForward(f.cfg.Tag, batch, chunk)
+
+	c.SetDeadline(time.Now().Add(f.cfg.Timeout))
+	if _, err := c.Write(msg); err != nil {
+		return err
+	}
+	ack, err := readAck(bufio.NewReader(c))
+	if err != nil {
+		return err
+	}
+	if ack != chunk {
+		return fmt.Errorf("fluentd acknowledged chunk %q, want %q", ack, chunk)
+	}
+	return nil
+}
+
+func newChunkID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// encodeForward encodes events in the forward protocol's Forward mode:
+// [tag, [[time, record], ...], {"chunk": chunk}].
+func encodeForward(tag string, events []fluentdEvent, chunk string) []byte {
+	buf := &bytes.Buffer{}
+	msgpackArray(buf, 3)
+	msgpackString(buf, tag)
+	msgpackArray(buf, len(events))
+	for _, e := range events {
+		msgpackArray(buf, 2)
+		msgpackEventTime(buf, e.t)
+		msgpackMap(buf, len(e.record))
+		for k, v := range e.record {
+			msgpackString(buf, k)
+			msgpackString(buf, v)
+		}
+	}
+	msgpackMap(buf, 1)
+	msgpackString(buf, "chunk")
+	msgpackString(buf, chunk)
+	return buf.Bytes()
+}
+
+func msgpackArray(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xdc)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdd)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func msgpackMap(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xde)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdf)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func msgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+// msgpackEventTime writes t as the forward protocol's EventTime, a fixext8
+// of type 0 holding seconds and nanoseconds.
+func msgpackEventTime(buf *bytes.Buffer, t time.Time) {
+	buf.WriteByte(0xd7)
+	buf.WriteByte(0x00)
+	binary.Write(buf, binary.BigEndian, uint32(t.Unix()))
+	binary.Write(buf, binary.BigEndian, uint32(t.Nanosecond()))
+}
+
+// readAck reads the {"ack": chunk} response to a forwarded batch.
+func readAck(r *bufio.Reader) (string, error) {
+	n, err := readMapHeader(r)
+	if err != nil {
+		return "", err
+	}
+	var ack string
+	for i := 0; i < n; i++ {
+		k, err := readString(r)
+		if err != nil {
+			return "", err
+		}
+		v, err := readString(r)
+		if err != nil {
+			return "", err
+		}
+		if k == "ack" {
+			ack = v
+		}
+	}
+	if ack == "" {
+		return "", errors.New("fluentd response has no ack")
+	}
+	return ack, nil
+}
+
+func readMapHeader(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b&0xf0 == 0x80:
+		return int(b & 0x0f), nil
+	case b == 0xde:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	case b == 0xdf:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	}
+	return 0, fmt.Errorf("unexpected msgpack type 0x%x, want map", b)
+}
+
+// maxFluentdString bounds the strings readString accepts, which is plenty for
+// the keys and values of an ack response, so that a bogus length cannot make
+// it allocate gigabytes.
+const maxFluentdString = 1024
+
+func readString(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9:
+		l, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		n = int(l)
+	case b == 0xda:
+		var l uint16
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return "", err
+		}
+		n = int(l)
+	case b == 0xdb:
+		var l uint32
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return "", err
+		}
+		n = int(l)
+	default:
+		return "", fmt.Errorf("unexpected msgpack type 0x%x, want string", b)
+	}
+	if n > maxFluentdString {
+		return "", fmt.Errorf("msgpack string of %d bytes exceeds %d", n, maxFluentdString)
+	}
+	s := make([]byte, n)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
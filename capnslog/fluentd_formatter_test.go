@@ -0,0 +1,286 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// forwardBatch is a decoded Forward mode message.
+type forwardBatch struct {
+	tag     string
+	records []map[string]string
+	chunk   string
+}
+
+func readArrayHeader(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b&0xf0 == 0x90:
+		return int(b & 0x0f), nil
+	case b == 0xdc:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	case b == 0xdd:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	}
+	return 0, fmt.Errorf("unexpected msgpack type 0x%x, want array", b)
+}
+
+func readStringMap(r *bufio.Reader) (map[string]string, error) {
+	n, err := readMapHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		if m[k], err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// readForward decodes a message written by encodeForward.
+func readForward(r *bufio.Reader) (*forwardBatch, error) {
+	if n, err := readArrayHeader(r); err != nil || n != 3 {
+		return nil, fmt.Errorf("message: want 3 elements, got %d (%v)", n, err)
+	}
+	tag, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := readArrayHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	batch := &forwardBatch{tag: tag}
+	for i := 0; i < n; i++ {
+		if n, err := readArrayHeader(r); err != nil || n != 2 {
+			return nil, fmt.Errorf("event: want 2 elements, got %d (%v)", n, err)
+		}
+		var t [10]byte
+		if _, err := io.ReadFull(r, t[:]); err != nil {
+			return nil, err
+		}
+		if t[0] != 0xd7 || t[1] != 0 {
+			return nil, fmt.Errorf("event time: unexpected header % x", t[:2])
+		}
+		record, err := readStringMap(r)
+		if err != nil {
+			return nil, err
+		}
+		batch.records = append(batch.records, record)
+	}
+	option, err := readStringMap(r)
+	if err != nil {
+		return nil, err
+	}
+	batch.chunk = option["chunk"]
+	return batch, nil
+}
+
+// writeAck writes the {"ack": chunk} response to a batch.
+func writeAck(w io.Writer, chunk string) error {
+	buf := &bytes.Buffer{}
+	msgpackMap(buf, 1)
+	msgpackString(buf, "ack")
+	msgpackString(buf, chunk)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// fakeFluentd accepts connections on l, decoding batches and sending them
+// on batches. The first drop connections are closed after reading a batch,
+// without acking it.
+func fakeFluentd(l net.Listener, drop int, batches chan<- *forwardBatch) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn, ack bool) {
+			defer c.Close()
+			r := bufio.NewReader(c)
+			for {
+				// A batch that fails to decode is never received, failing
+				// the test.
+				batch, err := readForward(r)
+				if err != nil {
+					return
+				}
+				if !ack {
+					return
+				}
+				batches <- batch
+				if err := writeAck(c, batch.chunk); err != nil {
+					return
+				}
+			}
+		}(c, drop <= 0)
+		drop--
+	}
+}
+
+func TestFluentdFormatter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	batches := make(chan *forwardBatch, 10)
+	go fakeFluentd(l, 0, batches)
+
+	f := NewFluentdFormatter(FluentdConfig{Addr: l.Addr().String(), Tag: "app"})
+	f.Format("pkg", WARNING, 0, Fields{"disk": "sda"}, " disk ", "full")
+	f.Flush()
+	f.Close()
+
+	select {
+	case batch := <-batches:
+		if batch.tag != "app" || batch.chunk == "" {
+			t.Errorf("unexpected tag %q or chunk %q", batch.tag, batch.chunk)
+		}
+		want := []map[string]string{{
+			"message": "disk=sda disk full",
+			"level":   "WARNING",
+			"package": "pkg",
+			"disk":    "sda",
+		}}
+		if !reflect.DeepEqual(want, batch.records) {
+			t.Errorf("want=%v got=%v", want, batch.records)
+		}
+	default:
+		t.Fatalf("no batch received")
+	}
+}
+
+func TestFluentdFormatterReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	batches := make(chan *forwardBatch, 10)
+	go fakeFluentd(l, 1, batches)
+
+	f := NewFluentdFormatter(FluentdConfig{
+		Addr:       l.Addr().String(),
+		MaxBackoff: 10 * time.Millisecond,
+	})
+	defer f.Close()
+	f.Format("pkg", INFO, 0, "resent")
+	f.Flush()
+
+	select {
+	case batch := <-batches:
+		if len(batch.records) != 1 || batch.records[0]["message"] != "resent" {
+			t.Errorf("unexpected records %v", batch.records)
+		}
+		if want := filepath.Base(os.Args[0]); batch.tag != want {
+			t.Errorf("default tag: want=%q got=%q", want, batch.tag)
+		}
+	default:
+		t.Fatalf("batch not resent after the connection dropped")
+	}
+}
+
+func TestFluentdReadStringLimit(t *testing.T) {
+	// A str 32 header claiming 4GiB is rejected before allocating.
+	r := bufio.NewReader(bytes.NewReader([]byte{0xdb, 0xff, 0xff, 0xff, 0xff}))
+	if _, err := readString(r); err == nil {
+		t.Errorf("expected error for an oversized string")
+	}
+	r = bufio.NewReader(bytes.NewReader([]byte{0xa3, 'a', 'c', 'k'}))
+	if s, err := readString(r); err != nil || s != "ack" {
+		t.Errorf("want %q, got %q (%v)", "ack", s, err)
+	}
+}
+
+func TestFluentdFormatterDropsOldest(t *testing.T) {
+	// No sender is started, so the queue only fills.
+	f := &FluentdFormatter{cfg: FluentdConfig{BufferSize: 2}}
+	f.cond = sync.NewCond(&f.mu)
+	for i := 0; i < 5; i++ {
+		f.Format("pkg", INFO, 0, i)
+	}
+	if want, got := 3, f.Dropped(); got != want {
+		t.Errorf("dropped: want=%d got=%d", want, got)
+	}
+	var got []string
+	for _, e := range f.queue {
+		got = append(got, e.record["message"])
+	}
+	if want := []string{"3", "4"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("queue: want=%v got=%v", want, got)
+	}
+}
+
+func TestFluentdFlushWithoutLock(t *testing.T) {
+	// Nothing listens on a closed listener's address, so Flush waits out
+	// FlushTimeout.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.Close()
+	f := NewFluentdFormatter(FluentdConfig{
+		Addr:         l.Addr().String(),
+		MaxBackoff:   10 * time.Millisecond,
+		FlushTimeout: 300 * time.Millisecond,
+	})
+	defer f.Close()
+	SetFormatter(f)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "fluentd")
+	p.Info("pending")
+	flushed := make(chan struct{})
+	go func() {
+		p.Flush()
+		close(flushed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	p.Info("while flushing")
+	select {
+	case <-flushed:
+		t.Errorf("logging waited for Flush")
+	default:
+	}
+	<-flushed
+}
@@ -29,6 +29,53 @@ type Formatter interface {
 	Flush()
 }
 
+// Clock provides the current time to formatters that write timestamps.
+// Substituting a fixed clock makes their output reproducible in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// CallerFlags selects which information about the logging call site a
+// formatter includes in its output.
+type CallerFlags uint8
+
+const (
+	// CallerFileLine includes the base name of the source file and the line
+	// number of the logging call.
+	CallerFileLine CallerFlags = 1 << iota
+	// CallerFunc includes the name of the function making the logging call.
+	CallerFunc
+)
+
+// callerInfo returns the call site depth frames above its caller.
+func callerInfo(depth int) (file string, line int, fn string) {
+	pc, file, line, ok := runtime.Caller(depth + 1)
+	if !ok {
+		return "???", 1, "???"
+	}
+	if slash := strings.LastIndex(file, "/"); slash >= 0 {
+		file = file[slash+1:]
+	}
+	if line < 0 {
+		line = 0 // not a real line number
+	}
+	fn = "???"
+	if f := runtime.FuncForPC(pc); f != nil {
+		fn = f.Name()
+		if slash := strings.LastIndex(fn, "/"); slash >= 0 {
+			fn = fn[slash+1:]
+		}
+	}
+	return file, line, fn
+}
+
 func NewStringFormatter(w io.Writer) Formatter {
 	return &StringFormatter{
 		w: bufio.NewWriter(w),
@@ -36,13 +83,41 @@ func NewStringFormatter(w io.Writer) Formatter {
 }
 
 type StringFormatter struct {
-	w *bufio.Writer
+	w      *bufio.Writer
+	caller CallerFlags
+	clock  Clock
+}
+
+// SetClock sets the clock used for timestamps. A nil Clock, the default,
+// uses time.Now.
+func (s *StringFormatter) SetClock(c Clock) {
+	s.clock = c
+}
+
+// SetCaller selects the call site information written before each entry.
+// Zero, the default, disables it.
+func (s *StringFormatter) SetCaller(flags CallerFlags) {
+	s.caller = flags
 }
 
 func (s *StringFormatter) Format(pkg string, l LogLevel, i int, entries ...interface{}) {
-	now := time.Now().UTC()
+	now := clockNow(s.clock).UTC()
 	s.w.WriteString(now.Format(time.RFC3339))
 	s.w.WriteByte(' ')
+	if s.caller != 0 {
+		file, line, fn := callerInfo(i)
+		s.w.WriteByte('[')
+		if s.caller&CallerFileLine != 0 {
+			s.w.WriteString(fmt.Sprintf("%s:%d", file, line))
+			if s.caller&CallerFunc != 0 {
+				s.w.WriteByte(' ')
+			}
+		}
+		if s.caller&CallerFunc != 0 {
+			s.w.WriteString(fn)
+		}
+		s.w.WriteString("] ")
+	}
 	writeEntries(s.w, pkg, l, i, entries...)
 	s.Flush()
 }
@@ -70,34 +145,76 @@ func NewPrettyFormatter(w io.Writer, debug bool) Formatter {
 	}
 }
 
+// NewAlignedPrettyFormatter returns a PrettyFormatter that pads package names
+// to a common width so that messages from different packages line up, which
+// makes interactive debugging output easier to scan.
+func NewAlignedPrettyFormatter(w io.Writer, debug bool) Formatter {
+	return &PrettyFormatter{
+		w:        bufio.NewWriter(w),
+		debug:    debug,
+		align:    true,
+		pkgWidth: longestPackageName(),
+	}
+}
+
 type PrettyFormatter struct {
 	w     *bufio.Writer
 	debug bool
+
+	// align pads package names to pkgWidth, which grows as longer
+	// package names are seen.
+	align    bool
+	pkgWidth int
+
+	clock Clock
+}
+
+// SetClock sets the clock used for timestamps. A nil Clock, the default,
+// uses time.Now.
+func (c *PrettyFormatter) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// longestPackageName returns the length of the longest package name
+// registered so far.
+func longestPackageName() int {
+	logger.Lock()
+	defer logger.Unlock()
+	n := 0
+	for _, r := range logger.repoMap {
+		for pkg := range r {
+			if len(pkg) > n {
+				n = len(pkg)
+			}
+		}
+	}
+	return n
 }
 
 func (c *PrettyFormatter) Format(pkg string, l LogLevel, depth int, entries ...interface{}) {
-	now := time.Now()
+	now := clockNow(c.clock)
 	ts := now.Format("2006-01-02 15:04:05")
 	c.w.WriteString(ts)
 	ms := now.Nanosecond() / 1000
 	c.w.WriteString(fmt.Sprintf(".%06d", ms))
 	if c.debug {
-		_, file, line, ok := runtime.Caller(depth) // It's always the same number of frames to the user's call.
-		if !ok {
-			file = "???"
-			line = 1
-		} else {
-			slash := strings.LastIndex(file, "/")
-			if slash >= 0 {
-				file = file[slash+1:]
-			}
-		}
-		if line < 0 {
-			line = 0 // not a real line number
-		}
+		file, line, _ := callerInfo(depth) // It's always the same number of frames to the user's call.
 		c.w.WriteString(fmt.Sprintf(" [%s:%d]", file, line))
 	}
 	c.w.WriteString(fmt.Sprint(" ", l.Char(), " | "))
+	if c.align {
+		if len(pkg) > c.pkgWidth {
+			c.pkgWidth = len(pkg)
+		}
+		sep := ": "
+		if pkg == "" {
+			sep = "  "
+		}
+		c.w.WriteString(pkg)
+		c.w.WriteString(sep)
+		c.w.WriteString(strings.Repeat(" ", c.pkgWidth-len(pkg)))
+		pkg = ""
+	}
 	writeEntries(c.w, pkg, l, depth, entries...)
 	c.Flush()
 }
@@ -0,0 +1,146 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStringFormatterCaller(t *testing.T) {
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "caller")
+	p.SetLevel(INFO)
+
+	for i, tt := range []struct {
+		flags CallerFlags
+		want  string
+	}{
+		{0, ""},
+		{CallerFileLine, "[formatters_test.go:%d] "},
+		{CallerFunc, "[capnslog.TestStringFormatterCaller] "},
+		{CallerFileLine | CallerFunc, "[formatters_test.go:%d capnslog.TestStringFormatterCaller] "},
+	} {
+		out := captureOutput(tt.flags)
+		lines := []int{nextLine()}
+		p.Info("info")
+		lines = append(lines, nextLine())
+		p.Infof("infof %d", 1)
+		lines = append(lines, nextLine())
+		p.Logf(WARNING, "logf %d", 2)
+
+		var want string
+		for j, msg := range []string{"info", "infof 1", "logf 2"} {
+			caller := tt.want
+			if strings.Contains(caller, "%d") {
+				caller = fmt.Sprintf(caller, lines[j])
+			}
+			want += "2016-01-02T15:04:05Z " + caller + "caller: " + msg + "\n"
+		}
+		if got := out.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestGlogFormatterCaller(t *testing.T) {
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "glog")
+	p.SetLevel(INFO)
+
+	for i, tt := range []struct {
+		flags CallerFlags
+		want  string
+	}{
+		{0, "W0102 15:04:05.123456Z %d formatters_test.go:%d] 2016-01-02T15:04:05Z glog: disk full\n"},
+		// File and line are always written, and not repeated.
+		{CallerFileLine, "W0102 15:04:05.123456Z %d formatters_test.go:%d] 2016-01-02T15:04:05Z glog: disk full\n"},
+		{CallerFunc, "W0102 15:04:05.123456Z %d formatters_test.go:%d capnslog.TestGlogFormatterCaller] 2016-01-02T15:04:05Z glog: disk full\n"},
+	} {
+		buf := &bytes.Buffer{}
+		g := NewGlogFormatter(buf)
+		g.SetClock(fixedClock(testTime))
+		g.SetCaller(tt.flags)
+		SetFormatter(g)
+
+		line := nextLine()
+		p.Warningf("disk %s", "full")
+		if want, got := fmt.Sprintf(tt.want, os.Getpid(), line), buf.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestAlignedPrettyFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	f := NewAlignedPrettyFormatter(buf, false).(*PrettyFormatter)
+	f.SetClock(fixedClock(testTime))
+	// Start from no known packages, rather than those registered by other
+	// tests.
+	f.pkgWidth = 0
+
+	f.Format("raft", INFO, 0, "elected leader")
+	f.Format("etcdserver", WARNING, 0, "slow fdatasync")
+	f.Format("raft", DEBUG, 0, "heartbeat")
+	f.Format("", ERROR, 0, "no package")
+
+	want := "2016-01-02 15:04:05.123456 I | raft: elected leader\n" +
+		"2016-01-02 15:04:05.123456 W | etcdserver: slow fdatasync\n" +
+		"2016-01-02 15:04:05.123456 D | raft:       heartbeat\n" +
+		"2016-01-02 15:04:05.123456 E |             no package\n"
+	if got := buf.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestFormatterClock(t *testing.T) {
+	// A clock in another zone, which StringFormatter converts to UTC.
+	clock := fixedClock(testTime.In(time.FixedZone("CET", 3600)))
+
+	for i, tt := range []struct {
+		newFormatter func(buf *bytes.Buffer) Formatter
+		want         string
+	}{
+		{
+			func(buf *bytes.Buffer) Formatter {
+				f := NewStringFormatter(buf).(*StringFormatter)
+				f.SetClock(clock)
+				return f
+			},
+			"2016-01-02T15:04:05Z pkg: msg\n",
+		},
+		{
+			func(buf *bytes.Buffer) Formatter {
+				f := NewPrettyFormatter(buf, false).(*PrettyFormatter)
+				f.SetClock(clock)
+				return f
+			},
+			"2016-01-02 16:04:05.123456 N | pkg: msg\n",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		f := tt.newFormatter(buf)
+		f.Format("pkg", NOTICE, 0, "msg")
+		f.Format("pkg", NOTICE, 0, "msg")
+		if want, got := tt.want+tt.want, buf.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
@@ -19,9 +19,7 @@ import (
 	"bytes"
 	"io"
 	"os"
-	"runtime"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -37,27 +35,24 @@ func NewGlogFormatter(w io.Writer) *GlogFormatter {
 	return g
 }
 
+// Format writes a glog-style header, which always carries the file:line of
+// the call site, followed by the entries. If CallerFunc has been set with
+// SetCaller, the calling function's name is added to the header.
 func (g GlogFormatter) Format(pkg string, level LogLevel, depth int, entries ...interface{}) {
-	g.w.Write(GlogHeader(level, depth+1))
+	g.w.Write(glogHeader(level, depth+1, g.caller&CallerFunc != 0, clockNow(g.clock)))
+	// The header already identifies the call site.
+	g.StringFormatter.caller = 0
 	g.StringFormatter.Format(pkg, level, depth+1, entries...)
 }
 
 func GlogHeader(level LogLevel, depth int) []byte {
+	return glogHeader(level, depth+1, false, time.Now())
+}
+
+func glogHeader(level LogLevel, depth int, withFunc bool, now time.Time) []byte {
 	// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
-	now := time.Now().UTC()
-	_, file, line, ok := runtime.Caller(depth) // It's always the same number of frames to the user's call.
-	if !ok {
-		file = "???"
-		line = 1
-	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
-		}
-	}
-	if line < 0 {
-		line = 0 // not a real line number
-	}
+	now = now.UTC()
+	file, line, fn := callerInfo(depth) // It's always the same number of frames to the user's call.
 	buf := &bytes.Buffer{}
 	buf.Grow(30)
 	_, month, day := now.Date()
@@ -80,6 +75,10 @@ func GlogHeader(level LogLevel, depth int) []byte {
 	buf.WriteString(file)
 	buf.WriteByte(':')
 	buf.WriteString(strconv.Itoa(line))
+	if withFunc {
+		buf.WriteByte(' ')
+		buf.WriteString(fn)
+	}
 	buf.WriteByte(']')
 	buf.WriteByte(' ')
 	return buf.Bytes()
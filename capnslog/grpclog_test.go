@@ -0,0 +1,99 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGRPCLogger(t *testing.T) {
+	out := captureOutput(CallerFileLine)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	var code int
+	SetExitFunc(func(c int) { code = c })
+	defer SetExitFunc(nil)
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "grpc")
+	p.SetLevel(INFO)
+	var g GRPCLogger = p
+
+	for i, tt := range []struct {
+		log  func() int
+		want string
+	}{
+		{
+			func() int {
+				line := nextLine()
+				g.Infoln("channel", "created")
+				return line
+			},
+			"grpc: channel created",
+		},
+		{
+			func() int {
+				line := nextLine()
+				g.Warningln("transport", "closing")
+				return line
+			},
+			"grpc: transport closing",
+		},
+		{
+			func() int {
+				line := nextLine()
+				g.Errorf("dial %s: %v", "10.0.0.1:2379", "refused")
+				return line
+			},
+			"grpc: dial 10.0.0.1:2379: refused",
+		},
+		{
+			func() int {
+				line := nextLine()
+				g.Errorln("stream", "reset")
+				return line
+			},
+			"grpc: stream reset",
+		},
+		{
+			func() int {
+				line := nextLine()
+				g.Fatalln("listener", "failed")
+				return line
+			},
+			"grpc: listener failed",
+		},
+	} {
+		out.Reset()
+		line := tt.log()
+		want := fmt.Sprintf("2016-01-02T15:04:05Z [grpclog_test.go:%d] %s\n", line, tt.want)
+		if got := out.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+	if code != 1 {
+		t.Errorf("Fatalln exit code: want=1 got=%d", code)
+	}
+
+	for v, want := range []bool{true, false, false} {
+		if got := g.V(v); got != want {
+			t.Errorf("V(%d) at INFO: want=%t got=%t", v, want, got)
+		}
+	}
+	p.SetLevel(DEBUG)
+	if !g.V(1) || g.V(2) {
+		t.Errorf("at DEBUG: want V(1) and not V(2)")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"sort"
+	"sync"
+)
+
+// LoggerInfo describes a registered package logger.
+type LoggerInfo struct {
+	Repo    string
+	Package string
+	Level   LogLevel
+}
+
+func (p *PackageLogger) info() LoggerInfo {
+	return LoggerInfo{
+		Repo:    p.repo,
+		Package: p.pkg,
+		Level:   p.getLevel(),
+	}
+}
+
+// Loggers returns every registered package logger, sorted by repository and
+// package name.
+func Loggers() []LoggerInfo {
+	logger.Lock()
+	var out []LoggerInfo
+	for _, r := range logger.repoMap {
+		for _, p := range r {
+			out = append(out, p.info())
+		}
+	}
+	logger.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Repo != out[j].Repo {
+			return out[i].Repo < out[j].Repo
+		}
+		return out[i].Package < out[j].Package
+	})
+	return out
+}
+
+var watchers struct {
+	sync.Mutex
+	fns  map[int]func(LoggerInfo)
+	next int
+}
+
+// WatchLoggers calls fn every time a package logger is registered or its
+// level changes. fn is called synchronously from the goroutine making the
+// change, without any capnslog locks held, so it may call back into capnslog
+// but should not block. The returned function stops the notifications.
+func WatchLoggers(fn func(LoggerInfo)) (cancel func()) {
+	watchers.Lock()
+	defer watchers.Unlock()
+	if watchers.fns == nil {
+		watchers.fns = make(map[int]func(LoggerInfo))
+	}
+	id := watchers.next
+	watchers.next++
+	watchers.fns[id] = fn
+	return func() {
+		watchers.Lock()
+		defer watchers.Unlock()
+		delete(watchers.fns, id)
+	}
+}
+
+// notifyWatchers must not be called with the logger lock held.
+func notifyWatchers(ps []*PackageLogger) {
+	if len(ps) == 0 {
+		return
+	}
+	watchers.Lock()
+	fns := make([]func(LoggerInfo), 0, len(watchers.fns))
+	for _, fn := range watchers.fns {
+		fns = append(fns, fn)
+	}
+	watchers.Unlock()
+
+	for _, p := range ps {
+		info := p.info()
+		for _, fn := range fns {
+			fn(info)
+		}
+	}
+}
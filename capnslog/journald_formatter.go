@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/coreos/go-systemd/journal"
 )
@@ -60,6 +61,13 @@ func (j *journaldFormatter) Format(pkg string, l LogLevel, _ int, entries ...int
 		"PACKAGE":           pkg,
 		"SYSLOG_IDENTIFIER": filepath.Base(os.Args[0]),
 	}
+	for _, entry := range entries {
+		if fe, ok := entry.(FieldsEntry); ok {
+			for k, v := range fe.LogFields() {
+				tags[journaldFieldName(k)] = v
+			}
+		}
+	}
 	err := journal.Send(msg, pri, tags)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -67,3 +75,18 @@ func (j *journaldFormatter) Format(pkg string, l LogLevel, _ int, entries ...int
 }
 
 func (j *journaldFormatter) Flush() {}
+
+// journaldFieldName converts a field name to the upper case letters, digits
+// and underscores accepted by journald.
+func journaldFieldName(k string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, k)
+}
@@ -0,0 +1,38 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//go:build !windows
+// +build !windows
+
+package capnslog
+
+import (
+	"testing"
+)
+
+func TestJournaldFieldName(t *testing.T) {
+	for i, tt := range []struct {
+		in, want string
+	}{
+		{"ERROR", "ERROR"},
+		{"error_chain", "ERROR_CHAIN"},
+		{"http.status-code", "HTTP_STATUS_CODE"},
+		{"retry2", "RETRY2"},
+		{"clé", "CL_"},
+	} {
+		if got := journaldFieldName(tt.in); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LoadLogLevelConfig reads a file of "pkg=level" settings and applies them with
+// SetLogLevel. Settings may be separated by commas or newlines; blank lines
+// and lines starting with '#' are ignored.
+func (r RepoLogger) LoadLogLevelConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var settings []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, s := range strings.Split(line, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				settings = append(settings, s)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+
+	cfg, err := r.ParseLogLevelConfig(strings.Join(settings, ","))
+	if err != nil {
+		return err
+	}
+	r.SetLogLevel(cfg)
+	return nil
+}
+
+// LevelConfigWatcher reapplies a log level config file to a RepoLogger when
+// the process receives SIGHUP, and optionally when the file changes on disk.
+type LevelConfigWatcher struct {
+	repo     RepoLogger
+	path     string
+	interval time.Duration
+	onError  func(error)
+	modTime  time.Time
+
+	sigc     chan os.Signal
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatchLogLevelConfig loads the config file at path into the repository and
+// reloads it every time SIGHUP is received. If interval is non-zero, the
+// file's modification time is also polled at that interval and the file is
+// reloaded when it changes. A reload that fails leaves the current levels
+// untouched and is reported to onError, or written to os.Stderr if onError is
+// nil.
+func (r RepoLogger) WatchLogLevelConfig(path string, interval time.Duration, onError func(error)) (*LevelConfigWatcher, error) {
+	w := &LevelConfigWatcher{
+		repo:     r,
+		path:     path,
+		interval: interval,
+		onError:  onError,
+		sigc:     make(chan os.Signal, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	signal.Notify(w.sigc, syscall.SIGHUP)
+	go w.run()
+	return w, nil
+}
+
+func (w *LevelConfigWatcher) run() {
+	defer close(w.done)
+	defer signal.Stop(w.sigc)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.sigc:
+			if err := w.reload(); err != nil {
+				w.reportError(err)
+			}
+		case <-tick:
+			fi, err := os.Stat(w.path)
+			if err != nil || fi.ModTime().Equal(w.modTime) {
+				continue
+			}
+			if err := w.reload(); err != nil {
+				w.reportError(err)
+			}
+		}
+	}
+}
+
+func (w *LevelConfigWatcher) reportError(err error) {
+	err = fmt.Errorf("failed to reload log level config %s: %v", w.path, err)
+	if w.onError != nil {
+		w.onError(err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+}
+
+func (w *LevelConfigWatcher) reload() error {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	w.modTime = fi.ModTime()
+	return w.repo.LoadLogLevelConfig(w.path)
+}
+
+// Stop stops watching for signals and file changes. It is safe to call more
+// than once.
+func (w *LevelConfigWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
@@ -0,0 +1,112 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const levelConfigRepo = "github.com/coreos/pkg/capnslog/levelconfig"
+
+func TestLoadLogLevelConfig(t *testing.T) {
+	a := NewPackageLogger(levelConfigRepo, "a")
+	b := NewPackageLogger(levelConfigRepo, "b")
+	bc := NewPackageLogger(levelConfigRepo, "b/c")
+	r := MustRepoLogger(levelConfigRepo)
+	path := filepath.Join(t.TempDir(), "levels")
+
+	for i, tt := range []struct {
+		conf    string
+		want    []LogLevel
+		wantErr bool
+	}{
+		{"a=DEBUG\nb=ERROR\n", []LogLevel{DEBUG, ERROR, INFO}, false},
+		{"# comment\n\n  a=T , b/*=W  \n", []LogLevel{TRACE, WARNING, WARNING}, false},
+		{"*=ERROR,b/c=DEBUG", []LogLevel{ERROR, ERROR, DEBUG}, false},
+		{"# nothing to set\n", []LogLevel{INFO, INFO, INFO}, false},
+		{"a", []LogLevel{INFO, INFO, INFO}, true},
+		{"a=DEBUG\nb=LOUD", []LogLevel{INFO, INFO, INFO}, true},
+	} {
+		r.SetRepoLogLevel(INFO)
+		if err := ioutil.WriteFile(path, []byte(tt.conf), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err := r.LoadLogLevelConfig(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want error %t, got %v", i, tt.wantErr, err)
+		}
+		for j, p := range []*PackageLogger{a, b, bc} {
+			if got := p.Level(); got != tt.want[j] {
+				t.Errorf("case %d: %s: want=%v got=%v", i, p.pkg, tt.want[j], got)
+			}
+		}
+	}
+
+	if err := r.LoadLogLevelConfig(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected error for a missing file")
+	}
+}
+
+func TestWatchLogLevelConfig(t *testing.T) {
+	p := NewPackageLogger(levelConfigRepo, "watched")
+	r := MustRepoLogger(levelConfigRepo)
+	path := filepath.Join(t.TempDir(), "levels")
+	mtime := time.Now()
+	write := func(conf string) {
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Coarse file system timestamps must not hide the change.
+		mtime = mtime.Add(time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	waitLevel := func(want LogLevel) {
+		deadline := time.Now().Add(5 * time.Second)
+		for p.Level() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("level: want=%v got=%v", want, p.Level())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	write("watched=DEBUG")
+	errc := make(chan error, 10)
+	w, err := r.WatchLogLevelConfig(path, 10*time.Millisecond, func(err error) { errc <- err })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Stop()
+	waitLevel(DEBUG)
+
+	write("watched=ERROR")
+	waitLevel(ERROR)
+
+	write("watched")
+	select {
+	case <-errc:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("OnError not called for an invalid config")
+	}
+	if got := p.Level(); got != ERROR {
+		t.Errorf("invalid config changed level to %v", got)
+	}
+}
@@ -16,24 +16,48 @@ package capnslog
 
 import (
 	"log"
+	"os"
 )
 
 func initHijack() {
-	pkg := NewPackageLogger("log", "")
-	w := packageWriter{pkg}
+	SetStdLogDestination("log", "", INFO)
+}
+
+// SetStdLogDestination routes output of the standard library's log package to
+// the given repository and package logger at the given level. By default it is
+// captured as the "log" repository with an empty package name at INFO.
+func SetStdLogDestination(repo, pkg string, l LogLevel) {
+	w := packageWriter{NewPackageLogger(repo, pkg), l}
 	log.SetFlags(0)
 	log.SetPrefix("")
 	log.SetOutput(w)
 }
 
+// DisableStdLogCapture stops capturing output of the standard library's log
+// package and restores its defaults of writing to os.Stderr with
+// log.LstdFlags.
+func DisableStdLogCapture() {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("")
+	log.SetOutput(os.Stderr)
+}
+
+// StdLogger returns a *log.Logger whose output is written to the package
+// logger at the given level. It is intended for APIs such as
+// http.Server.ErrorLog that only accept a standard library logger.
+func (p *PackageLogger) StdLogger(l LogLevel) *log.Logger {
+	return log.New(packageWriter{p, l}, "", 0)
+}
+
 type packageWriter struct {
-	pl *PackageLogger
+	pl    *PackageLogger
+	level LogLevel
 }
 
 func (p packageWriter) Write(b []byte) (int, error) {
-	if p.pl.level < INFO {
-		return 0, nil
+	if !p.pl.enabled(p.level) {
+		return len(b), nil
 	}
-	p.pl.internalLog(calldepth+2, INFO, string(b))
+	p.pl.internalLog(calldepth+2, p.level, string(b))
 	return len(b), nil
 }
@@ -0,0 +1,92 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	out := captureOutput(CallerFileLine)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "stdlogger")
+	p.SetLevel(INFO)
+
+	for i, tt := range []struct {
+		level LogLevel
+		write bool
+	}{
+		{WARNING, true},
+		{INFO, true},
+		{DEBUG, false},
+	} {
+		out.Reset()
+		std := p.StdLogger(tt.level)
+		line := nextLine()
+		std.Printf("http: TLS handshake error %d", i)
+
+		want := ""
+		if tt.write {
+			want = fmt.Sprintf("2016-01-02T15:04:05Z [log_hijack_test.go:%d] stdlogger: http: TLS handshake error %d\n", line, i)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestSetStdLogDestination(t *testing.T) {
+	r := NewRingFormatter(10)
+	SetFormatter(r)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	defer initHijack()
+
+	repo := "github.com/coreos/pkg/capnslog/stdlog"
+	for i, tt := range []struct {
+		pkg   string
+		level LogLevel
+		want  bool
+	}{
+		{"stdlib", WARNING, true},
+		{"", NOTICE, true},
+		// Below the destination logger's default level of INFO.
+		{"quiet", DEBUG, false},
+	} {
+		SetStdLogDestination(repo, tt.pkg, tt.level)
+		log.Printf("case %d", i)
+
+		var found bool
+		for _, e := range r.Entries() {
+			if e.Message == fmt.Sprintf("case %d\n", i) {
+				found = true
+				if e.Package != tt.pkg || e.Level != tt.level {
+					t.Errorf("case %d: want package %q at %v, got %q at %v", i, tt.pkg, tt.level, e.Package, e.Level)
+				}
+			}
+		}
+		if found != tt.want {
+			t.Errorf("case %d: want logged=%t got=%t", i, tt.want, found)
+		}
+	}
+
+	DisableStdLogCapture()
+	if log.Writer() != os.Stderr || log.Flags() != log.LstdFlags {
+		t.Errorf("standard logger not restored: writer=%v flags=%d", log.Writer(), log.Flags())
+	}
+}
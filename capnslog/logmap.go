@@ -16,8 +16,10 @@ package capnslog
 
 import (
 	"errors"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // LogLevel is the set of all log levels.
@@ -127,6 +129,12 @@ type loggerStruct struct {
 	sync.Mutex
 	repoMap   map[string]RepoLogger
 	formatter Formatter
+	redactors []Redactor
+
+	// hasFormatter is non-zero when formatter is set to something other
+	// than a NilFormatter. It is accessed atomically so that discarded
+	// entries need not take the lock.
+	hasFormatter int32
 }
 
 // logger is the global logger
@@ -135,10 +143,12 @@ var logger = new(loggerStruct)
 // SetGlobalLogLevel sets the log level for all packages in all repositories
 // registered with capnslog.
 func SetGlobalLogLevel(l LogLevel) {
+	var changed []*PackageLogger
+	defer func() { notifyWatchers(changed) }()
 	logger.Lock()
 	defer logger.Unlock()
 	for _, r := range logger.repoMap {
-		r.setRepoLogLevelInternal(l)
+		changed = append(changed, r.setRepoLogLevelInternal(l)...)
 	}
 }
 
@@ -164,15 +174,21 @@ func MustRepoLogger(repo string) RepoLogger {
 
 // SetRepoLogLevel sets the log level for all packages in the repository.
 func (r RepoLogger) SetRepoLogLevel(l LogLevel) {
+	var changed []*PackageLogger
+	defer func() { notifyWatchers(changed) }()
 	logger.Lock()
 	defer logger.Unlock()
-	r.setRepoLogLevelInternal(l)
+	changed = r.setRepoLogLevelInternal(l)
 }
 
-func (r RepoLogger) setRepoLogLevelInternal(l LogLevel) {
+// setRepoLogLevelInternal returns the loggers whose level changed.
+func (r RepoLogger) setRepoLogLevelInternal(l LogLevel) (changed []*PackageLogger) {
 	for _, v := range r {
-		v.level = l
+		if v.setLevel(l) {
+			changed = append(changed, v)
+		}
 	}
+	return changed
 }
 
 // ParseLogLevelConfig parses a comma-separated string of "package=loglevel", in
@@ -196,21 +212,48 @@ func (r RepoLogger) ParseLogLevelConfig(conf string) (map[string]LogLevel, error
 
 // SetLogLevel takes a map of package names within a repository to their desired
 // loglevel, and sets the levels appropriately. Unknown packages are ignored.
-// "*" is a special package name that corresponds to all packages, and will be
-// processed first.
+// "*" is a special package name that corresponds to all packages. A name
+// ending in "/*", such as "server/*", matches the "server" package and every
+// package below it. When several names match a package the most specific one
+// wins: an exact name beats any wildcard, and a longer prefix beats a shorter
+// one, with "*" being the least specific.
 func (r RepoLogger) SetLogLevel(m map[string]LogLevel) {
+	var changed []*PackageLogger
+	defer func() { notifyWatchers(changed) }()
 	logger.Lock()
 	defer logger.Unlock()
-	if l, ok := m["*"]; ok {
-		r.setRepoLogLevelInternal(l)
+	for pkg, p := range r {
+		if l, ok := matchLogLevel(m, pkg); ok && p.setLevel(l) {
+			changed = append(changed, p)
+		}
+	}
+}
+
+// matchLogLevel returns the level of the most specific entry in m matching pkg.
+func matchLogLevel(m map[string]LogLevel, pkg string) (LogLevel, bool) {
+	if l, ok := m[pkg]; ok {
+		return l, true
 	}
-	for k, v := range m {
-		l, ok := r[k]
-		if !ok {
-			continue
+	var (
+		level LogLevel
+		best  = -1
+	)
+	for k, l := range m {
+		n := -1
+		switch {
+		case k == "*":
+			n = 0
+		case strings.HasSuffix(k, "/*"):
+			prefix := strings.TrimSuffix(k, "*")
+			if pkg+"/" == prefix || strings.HasPrefix(pkg, prefix) {
+				n = len(prefix)
+			}
+		}
+		if n > best {
+			level, best = l, n
 		}
-		l.level = v
 	}
+	return level, best >= 0
 }
 
 // SetFormatter sets the formatting function for all logs.
@@ -218,11 +261,28 @@ func SetFormatter(f Formatter) {
 	logger.Lock()
 	defer logger.Unlock()
 	logger.formatter = f
+	var has int32
+	if _, discard := f.(*NilFormatter); f != nil && !discard {
+		has = 1
+	}
+	atomic.StoreInt32(&logger.hasFormatter, has)
+}
+
+// SetDiscard discards all log output. Entries are dropped before any
+// formatting takes place, so logging costs no more than a level check.
+func SetDiscard() {
+	SetFormatter(NewNilFormatter())
 }
 
 // NewPackageLogger creates a package logger object.
 // This should be defined as a global var in your package, referencing your repo.
 func NewPackageLogger(repo string, pkg string) (p *PackageLogger) {
+	var created bool
+	defer func() {
+		if created {
+			notifyWatchers([]*PackageLogger{p})
+		}
+	}()
 	logger.Lock()
 	defer logger.Unlock()
 	if logger.repoMap == nil {
@@ -236,10 +296,58 @@ func NewPackageLogger(repo string, pkg string) (p *PackageLogger) {
 	p, pok := r[pkg]
 	if !pok {
 		r[pkg] = &PackageLogger{
+			repo:  repo,
 			pkg:   pkg,
-			level: INFO,
+			level: int32(INFO),
 		}
 		p = r[pkg]
+		created = true
 	}
 	return
 }
+
+// NewPackageLoggerAuto creates a package logger like NewPackageLogger, but
+// derives the repository and package names from the import path of the
+// calling package, so they cannot drift after a rename or vendoring. For
+// import paths rooted at a hostname, the repository is the first three path
+// elements (e.g. "github.com/coreos/etcd") and the package is the rest (e.g.
+// "etcdserver/api"), or the last element for the repository root. Other
+// import paths, such as "main", are used whole as the repository and their
+// last element as the package.
+func NewPackageLoggerAuto() *PackageLogger {
+	pc, _, _, _ := runtime.Caller(1)
+	var fn string
+	if f := runtime.FuncForPC(pc); f != nil {
+		fn = f.Name()
+	}
+	repo, pkg := splitImportPath(funcImportPath(fn))
+	return NewPackageLogger(repo, pkg)
+}
+
+// funcImportPath returns the import path of the package that declares the
+// function with the given fully-qualified name, without any vendor prefix.
+func funcImportPath(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		fn = fn[:slash+1+dot]
+	}
+	// Dots in the last path element are escaped in symbol names.
+	fn = strings.Replace(fn, "%2e", ".", -1)
+	if i := strings.LastIndex(fn, "/vendor/"); i >= 0 {
+		fn = fn[i+len("/vendor/"):]
+	}
+	return fn
+}
+
+func splitImportPath(path string) (repo, pkg string) {
+	elems := strings.Split(path, "/")
+	if len(elems) >= 3 && strings.Contains(elems[0], ".") {
+		repo = strings.Join(elems[:3], "/")
+		pkg = strings.Join(elems[3:], "/")
+		if pkg == "" {
+			pkg = elems[2]
+		}
+		return repo, pkg
+	}
+	return path, elems[len(elems)-1]
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-logr/logr"
+)
+
+// Logr returns a logr.Logger that writes through the package logger. logr
+// verbosity 0 maps to INFO, 1 to DEBUG and anything higher to TRACE.
+func (p *PackageLogger) Logr() logr.Logger {
+	return logr.New(NewLogrSink(p))
+}
+
+// NewLogrSink returns a logr.LogSink backed by the given package logger, for
+// libraries such as client-go and controller-runtime that take a logr.Logger.
+func NewLogrSink(p *PackageLogger) logr.LogSink {
+	return &logrSink{p: p}
+}
+
+type logrSink struct {
+	p      *PackageLogger
+	name   string
+	values []interface{}
+	depth  int
+}
+
+func (s *logrSink) Init(info logr.RuntimeInfo) {
+	s.depth += info.CallDepth
+}
+
+func (s *logrSink) Enabled(level int) bool {
+	return s.p.LevelAt(verbosityLevel(level))
+}
+
+func (s *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.p.internalLog(calldepth+s.depth, verbosityLevel(level), s.render(msg, nil, keysAndValues))
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.p.internalLog(calldepth+s.depth, ERROR, s.render(msg, err, keysAndValues))
+}
+
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &c
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		name = c.name + "/" + name
+	}
+	c.name = name
+	return &c
+}
+
+func (s *logrSink) WithCallDepth(depth int) logr.LogSink {
+	c := *s
+	c.depth += depth
+	return &c
+}
+
+func (s *logrSink) render(msg string, err error, keysAndValues []interface{}) string {
+	buf := &bytes.Buffer{}
+	if s.name != "" {
+		buf.WriteString(s.name)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(msg)
+	if err != nil {
+		writeKeyValue(buf, "error", err.Error())
+	}
+	writeKeyValues(buf, s.values)
+	writeKeyValues(buf, keysAndValues)
+	return buf.String()
+}
+
+func writeKeyValues(buf *bytes.Buffer, kvs []interface{}) {
+	for i := 0; i < len(kvs); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(kvs) {
+			v = kvs[i+1]
+		}
+		writeKeyValue(buf, fmt.Sprint(kvs[i]), v)
+	}
+}
+
+func writeKeyValue(buf *bytes.Buffer, k string, v interface{}) {
+	buf.WriteByte(' ')
+	buf.WriteString(k)
+	buf.WriteByte('=')
+	if s, ok := v.(string); ok {
+		fmt.Fprintf(buf, "%q", s)
+		return
+	}
+	fmt.Fprint(buf, v)
+}
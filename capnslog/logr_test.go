@@ -0,0 +1,111 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestLogr(t *testing.T) {
+	out := captureOutput(CallerFileLine)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "logr")
+	p.SetLevel(DEBUG)
+	l := p.Logr()
+
+	for i, tt := range []struct {
+		log  func() int
+		want string
+	}{
+		{
+			func() int {
+				line := nextLine()
+				l.Info("reconciled", "pods", 3, "namespace", "default")
+				return line
+			},
+			`logr: reconciled pods=3 namespace="default"`,
+		},
+		{
+			func() int {
+				line := nextLine()
+				l.V(1).Info("cache synced")
+				return line
+			},
+			"logr: cache synced",
+		},
+		{
+			func() int {
+				l.V(2).Info("watch event")
+				return 0
+			},
+			"",
+		},
+		{
+			func() int {
+				line := nextLine()
+				l.Error(errors.New("conflict"), "update failed", "odd")
+				return line
+			},
+			`logr: update failed error="conflict" odd="(MISSING)"`,
+		},
+		{
+			func() int {
+				line := nextLine()
+				l.WithName("controller").WithName("pods").WithValues("worker", 1).Info("started")
+				return line
+			},
+			"logr: controller/pods: started worker=1",
+		},
+		{
+			func() int {
+				line := nextLine()
+				logrHelper(l, "from helper")
+				return line
+			},
+			"logr: from helper",
+		},
+	} {
+		out.Reset()
+		line := tt.log()
+		want := ""
+		if tt.want != "" {
+			want = fmt.Sprintf("2016-01-02T15:04:05Z [logr_test.go:%d] %s\n", line, tt.want)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+// logrHelper logs msg, attributing it to its caller.
+func logrHelper(l logr.Logger, msg string) {
+	l.WithCallDepth(1).Info(msg)
+}
+
+func TestLogrEnabled(t *testing.T) {
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "logr-enabled")
+	p.SetLevel(DEBUG)
+	l := p.Logr()
+	for v, want := range []bool{true, true, false, false} {
+		if got := l.V(v).Enabled(); got != want {
+			t.Errorf("V(%d): want=%t got=%t", v, want, got)
+		}
+	}
+}
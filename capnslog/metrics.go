@@ -0,0 +1,108 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MetricsCollector counts log entries by repository, package and level. Only
+// entries at or above their package's log level are counted. It serves the
+// counts in the Prometheus text exposition format as the counter
+// capnslog_entries_total, so alerting can be based on error rates without
+// scraping log files.
+type MetricsCollector struct {
+	mu     sync.Mutex
+	counts map[metricsKey]uint64
+}
+
+type metricsKey struct {
+	repo  string
+	pkg   string
+	level LogLevel
+}
+
+// NewMetricsCollector returns an empty MetricsCollector. It does not count
+// anything until installed with SetMetricsCollector.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		counts: make(map[metricsKey]uint64),
+	}
+}
+
+var metricsCollector atomic.Value // of *MetricsCollector
+
+// SetMetricsCollector installs c as the collector counting all log entries.
+// Passing nil disables counting.
+func SetMetricsCollector(c *MetricsCollector) {
+	metricsCollector.Store(c)
+}
+
+func loadMetricsCollector() *MetricsCollector {
+	c, _ := metricsCollector.Load().(*MetricsCollector)
+	return c
+}
+
+func (c *MetricsCollector) observe(repo, pkg string, l LogLevel) {
+	c.mu.Lock()
+	c.counts[metricsKey{repo, pkg, l}]++
+	c.mu.Unlock()
+}
+
+// Count returns the number of entries logged by the given package at the
+// given level.
+func (c *MetricsCollector) Count(repo, pkg string, l LogLevel) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[metricsKey{repo, pkg, l}]
+}
+
+// ServeHTTP writes the counts in the Prometheus text exposition format.
+func (c *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	keys := make([]metricsKey, 0, len(c.counts))
+	for k := range c.counts {
+		keys = append(keys, k)
+	}
+	counts := make([]uint64, len(keys))
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].repo != keys[j].repo {
+			return keys[i].repo < keys[j].repo
+		}
+		if keys[i].pkg != keys[j].pkg {
+			return keys[i].pkg < keys[j].pkg
+		}
+		return keys[i].level < keys[j].level
+	})
+	for i, k := range keys {
+		counts[i] = c.counts[k]
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP capnslog_entries_total Number of log entries by repository, package and level.")
+	fmt.Fprintln(w, "# TYPE capnslog_entries_total counter")
+	for i, k := range keys {
+		fmt.Fprintf(w, "capnslog_entries_total{repo=\"%s\",package=\"%s\",level=\"%s\"} %d\n",
+			labelEscaper.Replace(k.repo), labelEscaper.Replace(k.pkg), k.level, counts[i])
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,303 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPConfig configures an OTLPFormatter. Zero values select the defaults
+// noted on each field.
+type OTLPConfig struct {
+	// Endpoint is the URL of an OTLP/HTTP logs receiver. Defaults to
+	// "http://localhost:4318/v1/logs".
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute.
+	// Defaults to the program name.
+	ServiceName string
+	// Headers are added to every export request, e.g. for authentication.
+	Headers map[string]string
+	// BatchSize is the number of records that triggers an export. Defaults
+	// to 512.
+	BatchSize int
+	// Interval is the longest time a record waits before being exported.
+	// Defaults to 5 seconds.
+	Interval time.Duration
+	// Client is used to send export requests. Defaults to a client with a
+	// 10 second timeout.
+	Client *http.Client
+	// TraceContext extracts hex-encoded trace and span IDs from a
+	// context.Context passed as a log entry. With OpenTelemetry this is
+	// typically a call to trace.SpanContextFromContext. If nil, contexts
+	// are ignored.
+	TraceContext func(ctx context.Context) (traceID, spanID string)
+}
+
+// OTLPFormatter exports entries to an OpenTelemetry collector as OTLP log
+// records, encoded as JSON over HTTP. A context.Context may be passed among
+// the entries of a log call; it is not printed, but the trace and span IDs
+// extracted from it by TraceContext are attached to the record so that logs
+// can be correlated with traces:
+//
+//	plog.Info(ctx, "handled request")
+//
+// Records are batched and exported from a background goroutine. Export
+// errors are written to os.Stderr.
+type OTLPFormatter struct {
+	cfg OTLPConfig
+
+	mu      sync.Mutex
+	pending []otlpLogRecord
+	closed  bool
+	sendMu  sync.Mutex
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewOTLPFormatter returns an OTLPFormatter and starts its exporter.
+func NewOTLPFormatter(cfg OTLPConfig) *OTLPFormatter {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if cfg.ServiceName == "" && len(os.Args) > 0 {
+		cfg.ServiceName = filepath.Base(os.Args[0])
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	o := &OTLPFormatter{
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// blockingFlush marks OTLPFormatter as a blockingFlusher.
+func (o *OTLPFormatter) blockingFlush() {}
+
+// OTLP severity numbers, from the OpenTelemetry logs data model.
+func otlpSeverity(l LogLevel) int {
+	switch l {
+	case CRITICAL:
+		return 21 // FATAL
+	case ERROR:
+		return 17
+	case WARNING:
+		return 13
+	case NOTICE:
+		return 10 // INFO2
+	case INFO:
+		return 9
+	case DEBUG:
+		return 5
+	default:
+		return 1 // TRACE
+	}
+}
+
+func (o *OTLPFormatter) Format(pkg string, l LogLevel, _ int, entries ...interface{}) {
+	rec := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber: otlpSeverity(l),
+		SeverityText:   l.String(),
+		Attributes:     []otlpKeyValue{otlpString("capnslog.package", pkg)},
+	}
+
+	msg := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if ctx, ok := entry.(context.Context); ok {
+			if o.cfg.TraceContext != nil {
+				rec.TraceID, rec.SpanID = o.cfg.TraceContext(ctx)
+			}
+			continue
+		}
+		if fe, ok := entry.(FieldsEntry); ok {
+			fields := fe.LogFields()
+			keys := make([]string, 0, len(fields))
+			for k := range fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				rec.Attributes = append(rec.Attributes, otlpString(k, fields[k]))
+			}
+		}
+		msg = append(msg, entry)
+	}
+	rec.Body = otlpAnyValue{StringValue: fmt.Sprint(msg...)}
+
+	o.mu.Lock()
+	o.pending = append(o.pending, rec)
+	full := len(o.pending) >= o.cfg.BatchSize
+	o.mu.Unlock()
+	if full {
+		select {
+		case o.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports all pending records and waits for the export to finish.
+func (o *OTLPFormatter) Flush() {
+	if err := o.export(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Close exports pending records and stops the exporter. It is safe to call
+// more than once.
+func (o *OTLPFormatter) Close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.stop)
+	}
+	o.mu.Unlock()
+	<-o.done
+	o.Flush()
+}
+
+func (o *OTLPFormatter) run() {
+	defer close(o.done)
+	t := time.NewTicker(o.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-t.C:
+		case <-o.kick:
+		}
+		if err := o.export(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func (o *OTLPFormatter) export() error {
+	o.sendMu.Lock()
+	defer o.sendMu.Unlock()
+
+	o.mu.Lock()
+	records := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{otlpString("service.name", o.cfg.ServiceName)},
+			},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: "github.com/coreos/pkg/capnslog"},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", o.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting %d log records: %v", len(records), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting %d log records: unexpected status %s", len(records), resp.Status)
+	}
+	return nil
+}
+
+// The types below are the subset of the OTLP JSON encoding of
+// ExportLogsServiceRequest used by OTLPFormatter.
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{k, otlpAnyValue{v}}
+}
@@ -17,61 +17,151 @@ package capnslog
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 )
 
 type PackageLogger struct {
-	pkg   string
-	level LogLevel
+	repo string
+	pkg  string
+	// level is a LogLevel, stored as an int32 so that it can be read
+	// atomically without taking the global logger lock.
+	level int32
+
+	// parent and fields are set on child loggers created with WithFields.
+	parent *PackageLogger
+	fields Fields
 }
 
 const calldepth = 2
 
+// enabled reports whether an entry at the given level would be formatted,
+// counted or kept by the ring.
+func (p *PackageLogger) enabled(l LogLevel) bool {
+	if (p.emits(l) || vmoduleMayEnable(l)) && (atomic.LoadInt32(&logger.hasFormatter) != 0 || loadMetricsCollector() != nil) {
+		return true
+	}
+	return loadRing(l) != nil
+}
+
+// emits reports whether the package's level lets an entry at l through.
+func (p *PackageLogger) emits(l LogLevel) bool {
+	return l == CRITICAL || p.getLevel() >= l
+}
+
 func (p *PackageLogger) internalLog(depth int, inLevel LogLevel, entries ...interface{}) {
+	emit := p.emits(inLevel)
+	if l, ok := vmoduleLevel(depth); ok {
+		emit = inLevel == CRITICAL || l >= inLevel
+	}
+	ring := loadRing(inLevel)
+	if emit {
+		if c := loadMetricsCollector(); c != nil {
+			c.observe(p.repo, p.pkg, inLevel)
+		}
+	}
+	format := emit && atomic.LoadInt32(&logger.hasFormatter) != 0
+	if !format && ring == nil {
+		return
+	}
 	logger.Lock()
 	defer logger.Unlock()
-	if inLevel != CRITICAL && p.level < inLevel {
-		return
+	if p.fields != nil {
+		entries = append([]interface{}{p.fields, " "}, entries...)
 	}
-	if logger.formatter != nil {
+	entries = redact(entries)
+	if format && logger.formatter != nil {
 		logger.formatter.Format(p.pkg, inLevel, depth+1, entries...)
 	}
+	if ring != nil {
+		ring.Format(p.pkg, inLevel, depth+1, entries...)
+	}
 }
 
-// SetLevel allows users to change the current logging level.
+func (p *PackageLogger) getLevel() LogLevel {
+	return LogLevel(atomic.LoadInt32(&p.levelOwner().level))
+}
+
+// setLevel reports whether the level changed.
+func (p *PackageLogger) setLevel(l LogLevel) bool {
+	return atomic.SwapInt32(&p.levelOwner().level, int32(l)) != int32(l)
+}
+
+// Level returns the current logging level.
+func (p *PackageLogger) Level() LogLevel {
+	return p.getLevel()
+}
+
+// SetLevel allows users to change the current logging level. On a child
+// logger it changes the level of the package logger it derives from.
 func (p *PackageLogger) SetLevel(l LogLevel) {
-	logger.Lock()
-	defer logger.Unlock()
-	p.level = l
+	if p.setLevel(l) {
+		notifyWatchers([]*PackageLogger{p.levelOwner()})
+	}
 }
 
 // LevelAt checks if the given log level will be outputted under current setting.
 func (p *PackageLogger) LevelAt(l LogLevel) bool {
-	logger.Lock()
-	defer logger.Unlock()
-	return p.level >= l
+	return p.getLevel() >= l
+}
+
+// V reports whether logging at the given verbosity is enabled. Verbosity 0
+// corresponds to INFO, 1 to DEBUG and anything higher to TRACE. Together with
+// the *ln methods this lets a PackageLogger be used as a grpclog.LoggerV2.
+func (p *PackageLogger) V(v int) bool {
+	return p.LevelAt(verbosityLevel(v))
+}
+
+func verbosityLevel(v int) LogLevel {
+	switch {
+	case v <= 0:
+		return INFO
+	case v == 1:
+		return DEBUG
+	default:
+		return TRACE
+	}
 }
 
 // Log a formatted string at any level between ERROR and TRACE
 func (p *PackageLogger) Logf(l LogLevel, format string, args ...interface{}) {
-	p.internalLog(calldepth, l, fmt.Sprintf(format, args...))
+	p.logf(l, format, args...)
+}
+
+// logf implements Logf and the level-specific methods such as Infof, so
+// that each is the same number of frames above internalLog and formatters
+// find the call site of their caller, not of Logf.
+func (p *PackageLogger) logf(l LogLevel, format string, args ...interface{}) {
+	if !p.enabled(l) {
+		return
+	}
+	p.internalLog(calldepth+1, l, fmt.Sprintf(format, args...))
 }
 
 // Log a message at any level between ERROR and TRACE
 func (p *PackageLogger) Log(l LogLevel, args ...interface{}) {
+	if !p.enabled(l) {
+		return
+	}
 	p.internalLog(calldepth, l, fmt.Sprint(args...))
 }
 
 // log stdlib compatibility
 
 func (p *PackageLogger) Println(args ...interface{}) {
+	if !p.enabled(INFO) {
+		return
+	}
 	p.internalLog(calldepth, INFO, fmt.Sprintln(args...))
 }
 
 func (p *PackageLogger) Printf(format string, args ...interface{}) {
-	p.Logf(INFO, format, args...)
+	p.logf(INFO, format, args...)
 }
 
 func (p *PackageLogger) Print(args ...interface{}) {
+	if !p.enabled(INFO) {
+		return
+	}
 	p.internalLog(calldepth, INFO, fmt.Sprint(args...))
 }
 
@@ -95,47 +185,96 @@ func (p *PackageLogger) Panicln(args ...interface{}) {
 	panic(s)
 }
 
+var (
+	fatalExitCode = 1
+	exitFunc      = os.Exit
+)
+
+// SetFatalExitCode sets the status the Fatal methods exit with. It is 1 by
+// default.
+func SetFatalExitCode(code int) {
+	logger.Lock()
+	defer logger.Unlock()
+	fatalExitCode = code
+}
+
+// SetExitFunc replaces os.Exit as the function the Fatal methods call to
+// terminate the program, for example to exercise fatal paths in tests. A nil
+// function restores os.Exit.
+func SetExitFunc(f func(code int)) {
+	logger.Lock()
+	defer logger.Unlock()
+	if f == nil {
+		f = os.Exit
+	}
+	exitFunc = f
+}
+
+// fatalExit flushes the formatter so that buffered output is not lost, then
+// exits.
+func fatalExit() {
+	flushFormatter()
+	logger.Lock()
+	exit, code := exitFunc, fatalExitCode
+	logger.Unlock()
+	exit(code)
+}
+
 func (p *PackageLogger) Fatalf(format string, args ...interface{}) {
-	p.Logf(CRITICAL, format, args...)
-	os.Exit(1)
+	p.logf(CRITICAL, format, args...)
+	fatalExit()
 }
 
 func (p *PackageLogger) Fatal(args ...interface{}) {
 	s := fmt.Sprint(args...)
 	p.internalLog(calldepth, CRITICAL, s)
-	os.Exit(1)
+	fatalExit()
 }
 
 func (p *PackageLogger) Fatalln(args ...interface{}) {
 	s := fmt.Sprintln(args...)
 	p.internalLog(calldepth, CRITICAL, s)
-	os.Exit(1)
+	fatalExit()
 }
 
 // Error Functions
 
 func (p *PackageLogger) Errorf(format string, args ...interface{}) {
-	p.Logf(ERROR, format, args...)
+	p.logf(ERROR, format, args...)
 }
 
 func (p *PackageLogger) Error(entries ...interface{}) {
 	p.internalLog(calldepth, ERROR, entries...)
 }
 
+func (p *PackageLogger) Errorln(args ...interface{}) {
+	if !p.enabled(ERROR) {
+		return
+	}
+	p.internalLog(calldepth, ERROR, fmt.Sprintln(args...))
+}
+
 // Warning Functions
 
 func (p *PackageLogger) Warningf(format string, args ...interface{}) {
-	p.Logf(WARNING, format, args...)
+	p.logf(WARNING, format, args...)
 }
 
 func (p *PackageLogger) Warning(entries ...interface{}) {
 	p.internalLog(calldepth, WARNING, entries...)
 }
 
+func (p *PackageLogger) Warningln(args ...interface{}) {
+	if !p.enabled(WARNING) {
+		return
+	}
+	p.internalLog(calldepth, WARNING, fmt.Sprintln(args...))
+}
+
 // Notice Functions
 
 func (p *PackageLogger) Noticef(format string, args ...interface{}) {
-	p.Logf(NOTICE, format, args...)
+	p.logf(NOTICE, format, args...)
 }
 
 func (p *PackageLogger) Notice(entries ...interface{}) {
@@ -145,24 +284,31 @@ func (p *PackageLogger) Notice(entries ...interface{}) {
 // Info Functions
 
 func (p *PackageLogger) Infof(format string, args ...interface{}) {
-	p.Logf(INFO, format, args...)
+	p.logf(INFO, format, args...)
 }
 
 func (p *PackageLogger) Info(entries ...interface{}) {
 	p.internalLog(calldepth, INFO, entries...)
 }
 
+func (p *PackageLogger) Infoln(args ...interface{}) {
+	if !p.enabled(INFO) {
+		return
+	}
+	p.internalLog(calldepth, INFO, fmt.Sprintln(args...))
+}
+
 // Debug Functions
 
 func (p *PackageLogger) Debugf(format string, args ...interface{}) {
-	if p.level < DEBUG {
+	if !p.enabled(DEBUG) {
 		return
 	}
-	p.Logf(DEBUG, format, args...)
+	p.logf(DEBUG, format, args...)
 }
 
 func (p *PackageLogger) Debug(entries ...interface{}) {
-	if p.level < DEBUG {
+	if !p.enabled(DEBUG) {
 		return
 	}
 	p.internalLog(calldepth, DEBUG, entries...)
@@ -171,21 +317,43 @@ func (p *PackageLogger) Debug(entries ...interface{}) {
 // Trace Functions
 
 func (p *PackageLogger) Tracef(format string, args ...interface{}) {
-	if p.level < TRACE {
+	if !p.enabled(TRACE) {
 		return
 	}
-	p.Logf(TRACE, format, args...)
+	p.logf(TRACE, format, args...)
 }
 
 func (p *PackageLogger) Trace(entries ...interface{}) {
-	if p.level < TRACE {
+	if !p.enabled(TRACE) {
 		return
 	}
 	p.internalLog(calldepth, TRACE, entries...)
 }
 
 func (p *PackageLogger) Flush() {
+	flushFormatter()
+}
+
+// blockingFlusher is a Formatter whose Flush may block, waiting on the
+// network, and synchronizes itself, so that it can be flushed without
+// holding the lock shared by all loggers.
+type blockingFlusher interface {
+	Formatter
+	blockingFlush()
+}
+
+// flushFormatter flushes the formatter under the logger lock, except for a
+// blockingFlusher, which is flushed after releasing it.
+func flushFormatter() {
 	logger.Lock()
+	f := logger.formatter
+	if bf, ok := f.(blockingFlusher); ok {
+		logger.Unlock()
+		bf.Flush()
+		return
+	}
 	defer logger.Unlock()
-	logger.formatter.Flush()
+	if f != nil {
+		f.Flush()
+	}
 }
@@ -0,0 +1,417 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock that always returns the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+var testTime = time.Date(2016, 1, 2, 15, 4, 5, 123456000, time.UTC)
+
+// captureOutput installs a StringFormatter writing entries to the returned
+// buffer, stamped with testTime and the given call site information.
+func captureOutput(caller CallerFlags) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	f := NewStringFormatter(buf).(*StringFormatter)
+	f.SetClock(fixedClock(testTime))
+	f.SetCaller(caller)
+	SetFormatter(f)
+	return buf
+}
+
+// nextLine returns the number of the line after the one it is called from.
+func nextLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line + 1
+}
+
+func BenchmarkBelowLevelParallel(b *testing.B) {
+	SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "bench")
+	p.SetLevel(WARNING)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Info("dropped")
+			p.Debug("dropped")
+		}
+	})
+}
+
+func BenchmarkNilFormatterParallel(b *testing.B) {
+	SetFormatter(nil)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "bench")
+	p.SetLevel(INFO)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Info("dropped")
+		}
+	})
+}
+
+func BenchmarkDiscardParallel(b *testing.B) {
+	SetDiscard()
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "bench")
+	p.SetLevel(INFO)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Infof("dropped %d", 1)
+		}
+	})
+}
+
+func BenchmarkLevelAtParallel(b *testing.B) {
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "bench")
+	p.SetLevel(INFO)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.LevelAt(DEBUG)
+		}
+	})
+}
+
+func TestSplitImportPath(t *testing.T) {
+	tests := []struct {
+		fn   string
+		repo string
+		pkg  string
+	}{
+		{"github.com/coreos/etcd/etcdserver.init", "github.com/coreos/etcd", "etcdserver"},
+		{"github.com/coreos/etcd/etcdserver/api/v2.(*Server).Start", "github.com/coreos/etcd", "etcdserver/api/v2"},
+		{"github.com/coreos/pkg.init.0", "github.com/coreos/pkg", "pkg"},
+		{"github.com/example/app/vendor/github.com/coreos/etcd/raft.init", "github.com/coreos/etcd", "raft"},
+		{"gopkg.in/yaml%2ev2.init", "gopkg.in/yaml.v2", "yaml.v2"},
+		{"main.main", "main", "main"},
+	}
+
+	for i, tt := range tests {
+		repo, pkg := splitImportPath(funcImportPath(tt.fn))
+		if repo != tt.repo || pkg != tt.pkg {
+			t.Errorf("case %d: want=(%q, %q) got=(%q, %q)", i, tt.repo, tt.pkg, repo, pkg)
+		}
+	}
+}
+
+func TestNewPackageLoggerAuto(t *testing.T) {
+	p := NewPackageLoggerAuto()
+	r, err := GetRepoLogger("github.com/coreos/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r["capnslog"] != p {
+		t.Errorf("logger not registered as github.com/coreos/pkg capnslog")
+	}
+}
+
+func TestSetLogLevelPrefix(t *testing.T) {
+	repo := "github.com/coreos/pkg/capnslog/prefix"
+	names := []string{"main", "server", "server/api", "server/api/v2", "serverless"}
+	for _, n := range names {
+		NewPackageLogger(repo, n)
+	}
+	r := MustRepoLogger(repo)
+	cfg, err := r.ParseLogLevelConfig("*=WARNING,server/*=DEBUG,server/api/*=TRACE,server/api=INFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetLogLevel(cfg)
+
+	for pkg, want := range map[string]LogLevel{
+		"main":          WARNING,
+		"server":        DEBUG,
+		"server/api":    INFO,
+		"server/api/v2": TRACE,
+		"serverless":    WARNING,
+	} {
+		if got := r[pkg].getLevel(); got != want {
+			t.Errorf("package %q: want=%v got=%v", pkg, want, got)
+		}
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	c := NewMetricsCollector()
+	SetMetricsCollector(c)
+	defer SetMetricsCollector(nil)
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "metrics")
+	p.SetLevel(WARNING)
+	p.Error("one")
+	p.Errorf("two")
+	p.Warning("three")
+	p.Info("dropped")
+
+	if n := c.Count("github.com/coreos/pkg/capnslog", "metrics", ERROR); n != 2 {
+		t.Errorf("ERROR count: want=2 got=%d", n)
+	}
+	if n := c.Count("github.com/coreos/pkg/capnslog", "metrics", WARNING); n != 1 {
+		t.Errorf("WARNING count: want=1 got=%d", n)
+	}
+	if n := c.Count("github.com/coreos/pkg/capnslog", "metrics", INFO); n != 0 {
+		t.Errorf("INFO count: want=0 got=%d", n)
+	}
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	want := `capnslog_entries_total{repo="github.com/coreos/pkg/capnslog",package="metrics",level="ERROR"} 2`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("metrics output missing %q:\n%s", want, w.Body.String())
+	}
+}
+
+func TestFatalExit(t *testing.T) {
+	var code int
+	SetExitFunc(func(c int) { code = c })
+	SetFatalExitCode(3)
+	defer func() {
+		SetExitFunc(nil)
+		SetFatalExitCode(1)
+	}()
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "fatal")
+	p.Fatal("exiting")
+	if code != 3 {
+		t.Errorf("exit code: want=3 got=%d", code)
+	}
+}
+
+func TestWatchLoggers(t *testing.T) {
+	var got []LoggerInfo
+	cancel := WatchLoggers(func(i LoggerInfo) {
+		got = append(got, i)
+		// Calling back into capnslog must not deadlock.
+		Loggers()
+	})
+
+	repo := "github.com/coreos/pkg/capnslog/watch"
+	p := NewPackageLogger(repo, "a")
+	p.SetLevel(DEBUG)
+	p.SetLevel(DEBUG)
+	MustRepoLogger(repo).SetRepoLogLevel(ERROR)
+	cancel()
+	p.SetLevel(TRACE)
+
+	want := []LoggerInfo{
+		{repo, "a", INFO},
+		{repo, "a", DEBUG},
+		{repo, "a", ERROR},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+
+	found := false
+	for _, i := range Loggers() {
+		if i.Repo == repo && i.Package == "a" {
+			found = i.Level == TRACE
+		}
+	}
+	if !found {
+		t.Errorf("Loggers() does not report %s a at TRACE", repo)
+	}
+}
+
+func TestRingBelowPackageLevel(t *testing.T) {
+	r := NewRingFormatter(2)
+	SetRing(r, DEBUG)
+	defer SetRing(nil, DEBUG)
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "ring")
+	p.SetLevel(INFO)
+	p.Debugf("debug %d", 1)
+	p.Debugf("debug %d", 2)
+	p.Debugf("debug %d", 3)
+	p.Tracef("trace")
+	p.Info("info")
+
+	var got []string
+	for _, e := range r.Entries(DEBUG) {
+		got = append(got, e.Message)
+	}
+	if want := []string{"debug 2", "debug 3"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("DEBUG entries: want=%v got=%v", want, got)
+	}
+	if n := len(r.Entries()); n != 3 {
+		t.Errorf("all entries: want=3 got=%d", n)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/?level=info&format=json", nil))
+	if want := `"message":"info","level":"INFO"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("JSON output missing %q: %s", want, w.Body.String())
+	}
+}
+
+func TestVModule(t *testing.T) {
+	r := NewRingFormatter(10)
+	SetFormatter(r)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "vmodule")
+	p.SetLevel(INFO)
+
+	if err := SetVModule("pkg_logger*=5,other=ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	p.Trace("traced")
+	if err := SetVModule("capnslog/pkg_logger_test=ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	p.Info("suppressed")
+	if err := SetVModule(""); err != nil {
+		t.Fatal(err)
+	}
+	p.Trace("suppressed")
+	p.Info("info")
+
+	var got []string
+	for _, e := range r.Entries() {
+		got = append(got, e.Message)
+	}
+	if want := []string{"traced", "info"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+
+	if err := SetVModule("foo"); err == nil {
+		t.Errorf("expected error for malformed spec")
+	}
+}
+
+func TestOTLPFormatter(t *testing.T) {
+	var body []byte
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer s.Close()
+
+	type spanKey struct{}
+	o := NewOTLPFormatter(OTLPConfig{
+		Endpoint: s.URL,
+		TraceContext: func(ctx context.Context) (string, string) {
+			span, _ := ctx.Value(spanKey{}).(string)
+			return "0af7651916cd43dd8448eb211c80319c", span
+		},
+	})
+	ctx := context.WithValue(context.Background(), spanKey{}, "b7ad6b7169203331")
+	o.Format("otlp", WARNING, 0, ctx, "disk ", "full")
+	o.Close()
+	// A second Close must not panic.
+	o.Close()
+
+	for _, want := range []string{
+		`"severityNumber":13`,
+		`"body":{"stringValue":"disk full"}`,
+		`"traceId":"0af7651916cd43dd8448eb211c80319c"`,
+		`"spanId":"b7ad6b7169203331"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("export missing %q: %s", want, body)
+		}
+	}
+}
+
+func TestOTLPFlushWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer s.Close()
+	o := NewOTLPFormatter(OTLPConfig{Endpoint: s.URL})
+	SetFormatter(o)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "otlp")
+	p.Info("pending")
+	flushed := make(chan struct{})
+	go func() {
+		p.Flush()
+		close(flushed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	logged := make(chan struct{})
+	go func() {
+		p.Info("while flushing")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Errorf("logging waited for Flush")
+	}
+	select {
+	case <-flushed:
+		t.Errorf("Flush returned before the export finished")
+	default:
+	}
+	close(release)
+	<-flushed
+	o.Close()
+}
+
+func TestWithFields(t *testing.T) {
+	r := NewRingFormatter(4)
+	SetRing(r, TRACE)
+	defer SetRing(nil, TRACE)
+
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "child")
+	p.SetLevel(INFO)
+	worker := p.WithFields(Fields{"worker": "3"})
+	req := worker.WithFields(Fields{"request": "abc"})
+
+	req.Infof("handled %d", 1)
+	if req.LevelAt(DEBUG) {
+		t.Errorf("child at DEBUG before parent level change")
+	}
+	p.SetLevel(DEBUG)
+	if !req.LevelAt(DEBUG) {
+		t.Errorf("child did not follow parent level change")
+	}
+	req.SetLevel(WARNING)
+	if l := p.Level(); l != WARNING {
+		t.Errorf("parent level: want=WARNING got=%v", l)
+	}
+
+	e := r.Entries(INFO)
+	if len(e) != 1 {
+		t.Fatalf("INFO entries: want=1 got=%d", len(e))
+	}
+	if want := "request=abc worker=3 handled 1"; e[0].Message != want {
+		t.Errorf("message: want=%q got=%q", want, e[0].Message)
+	}
+	if e[0].Package != "child" {
+		t.Errorf("package: want=child got=%q", e[0].Package)
+	}
+}
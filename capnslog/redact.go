@@ -0,0 +1,60 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A Redactor rewrites the text of a log entry before it is handed to the
+// formatter, for example to mask tokens or passwords.
+type Redactor func(msg string) string
+
+// RedactRegexp returns a Redactor that replaces every match of re with repl.
+// repl may refer to submatches as in regexp.Regexp.ReplaceAllString.
+func RedactRegexp(re *regexp.Regexp, repl string) Redactor {
+	return func(msg string) string {
+		return re.ReplaceAllString(msg, repl)
+	}
+}
+
+// AddRedactor registers a Redactor that is applied to every entry logged by
+// any package. Redactors run in the order they were added. When at least one
+// is registered, entries are rendered to a single string before formatting.
+func AddRedactor(r Redactor) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.redactors = append(logger.redactors, r)
+}
+
+// ClearRedactors removes all registered Redactors.
+func ClearRedactors() {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.redactors = nil
+}
+
+// redact must be called with the logger lock held.
+func redact(entries []interface{}) []interface{} {
+	if len(logger.redactors) == 0 {
+		return entries
+	}
+	msg := fmt.Sprint(entries...)
+	for _, r := range logger.redactors {
+		msg = r(msg)
+	}
+	return []interface{}{msg}
+}
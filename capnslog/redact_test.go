@@ -0,0 +1,71 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactors(t *testing.T) {
+	r := NewRingFormatter(1)
+	SetFormatter(r)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	defer ClearRedactors()
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "redact")
+	p.SetLevel(INFO)
+
+	for i, tt := range []struct {
+		redactors []Redactor
+		entries   []interface{}
+		want      string
+	}{
+		{
+			entries: []interface{}{"token=abc123"},
+			want:    "token=abc123",
+		},
+		{
+			redactors: []Redactor{RedactRegexp(regexp.MustCompile(`token=\S+`), "token=REDACTED")},
+			entries:   []interface{}{"login ", "token=abc123", " ok"},
+			want:      "login token=REDACTED ok",
+		},
+		{
+			redactors: []Redactor{RedactRegexp(regexp.MustCompile(`(password|secret)=\S+`), "$1=***")},
+			entries:   []interface{}{"password=hunter2 secret=s3 ", 42},
+			want:      "password=*** secret=*** 42",
+		},
+		{
+			// Redactors run in the order they were added.
+			redactors: []Redactor{
+				RedactRegexp(regexp.MustCompile(`\d{4}-\d{4}`), "CARD"),
+				strings.ToLower,
+			},
+			entries: []interface{}{"Card 1234-5678"},
+			want:    "card card",
+		},
+	} {
+		ClearRedactors()
+		for _, rd := range tt.redactors {
+			AddRedactor(rd)
+		}
+		p.Info(tt.entries...)
+		e := r.Entries(INFO)
+		if len(e) != 1 || e[0].Message != tt.want {
+			t.Errorf("case %d: want=%q got=%v", i, tt.want, e)
+		}
+	}
+}
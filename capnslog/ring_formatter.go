@@ -0,0 +1,192 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RingEntry is a log entry retained by a RingFormatter.
+type RingEntry struct {
+	Time    time.Time `json:"time"`
+	Package string    `json:"package"`
+	Level   LogLevel  `json:"-"`
+	Message string    `json:"message"`
+}
+
+// MarshalJSON renders the level by name.
+func (e RingEntry) MarshalJSON() ([]byte, error) {
+	type entry RingEntry
+	return json.Marshal(struct {
+		entry
+		Level string `json:"level"`
+	}{entry(e), e.Level.String()})
+}
+
+func (e RingEntry) String() string {
+	msg := strings.TrimSuffix(e.Message, "\n")
+	if e.Package != "" {
+		msg = e.Package + ": " + msg
+	}
+	return fmt.Sprintf("%s %s | %s", e.Time.UTC().Format("2006-01-02 15:04:05.000000"), e.Level.Char(), msg)
+}
+
+// RingFormatter keeps the most recent entries of each level in memory. It
+// can be used as a regular Formatter, but installed with SetRing it also sees
+// entries below the packages' log levels, so that recent DEBUG output of a
+// running service can be inspected through its ServeHTTP method without
+// changing what is written to the persistent log.
+type RingFormatter struct {
+	mu    sync.Mutex
+	size  int
+	rings map[LogLevel]*entryRing
+}
+
+type entryRing struct {
+	entries []RingEntry
+	next    int
+	full    bool
+}
+
+func (r *entryRing) add(e RingEntry) {
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *entryRing) appendTo(out []RingEntry) []RingEntry {
+	if r.full {
+		out = append(out, r.entries[r.next:]...)
+	}
+	return append(out, r.entries[:r.next]...)
+}
+
+// NewRingFormatter returns a RingFormatter retaining up to n entries per
+// level.
+func NewRingFormatter(n int) *RingFormatter {
+	if n < 1 {
+		n = 1
+	}
+	return &RingFormatter{
+		size:  n,
+		rings: make(map[LogLevel]*entryRing),
+	}
+}
+
+func (r *RingFormatter) Format(pkg string, l LogLevel, _ int, entries ...interface{}) {
+	e := RingEntry{
+		Time:    time.Now(),
+		Package: pkg,
+		Level:   l,
+		Message: fmt.Sprint(entries...),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ring, ok := r.rings[l]
+	if !ok {
+		ring = &entryRing{entries: make([]RingEntry, r.size)}
+		r.rings[l] = ring
+	}
+	ring.add(e)
+}
+
+// Flush is included so that the interface is complete, but is a no-op.
+func (r *RingFormatter) Flush() {}
+
+// Entries returns the retained entries of the given levels, oldest first. If
+// no level is given, entries of all levels are returned.
+func (r *RingFormatter) Entries(levels ...LogLevel) []RingEntry {
+	r.mu.Lock()
+	var out []RingEntry
+	if len(levels) == 0 {
+		for _, ring := range r.rings {
+			out = ring.appendTo(out)
+		}
+	} else {
+		for _, l := range levels {
+			if ring, ok := r.rings[l]; ok {
+				out = ring.appendTo(out)
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+	return out
+}
+
+// ServeHTTP dumps the retained entries, oldest first. The "level" query
+// parameter, which may be repeated, restricts the output to the given levels.
+// With "format=json" the entries are written as a JSON array instead of text
+// lines.
+func (r *RingFormatter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var levels []LogLevel
+	for _, s := range req.URL.Query()["level"] {
+		l, err := ParseLevel(strings.ToUpper(s))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		levels = append(levels, l)
+	}
+	entries := r.Entries(levels...)
+
+	if req.URL.Query().Get("format") == "json" {
+		if entries == nil {
+			entries = []RingEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range entries {
+		fmt.Fprintln(w, e)
+	}
+}
+
+type ringSink struct {
+	r     *RingFormatter
+	level LogLevel
+}
+
+var ring atomic.Value // of ringSink
+
+// SetRing installs r to receive every entry at or above level l from all
+// packages, regardless of the packages' own log levels and of the formatter
+// set with SetFormatter. A nil RingFormatter uninstalls it.
+func SetRing(r *RingFormatter, l LogLevel) {
+	ring.Store(ringSink{r, l})
+}
+
+// loadRing returns the installed ring if it accepts entries at level l.
+func loadRing(l LogLevel) *RingFormatter {
+	s, _ := ring.Load().(ringSink)
+	if s.r == nil || l > s.level {
+		return nil
+	}
+	return s.r
+}
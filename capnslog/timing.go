@@ -0,0 +1,56 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimingEntry is a log entry recording how long an operation took.
+type TimingEntry struct {
+	Operation string
+	Duration  time.Duration
+}
+
+func (t TimingEntry) String() string {
+	return fmt.Sprintf("%s took %v", t.Operation, t.Duration)
+}
+
+// LogFields returns the operation and its duration, both as a string and in
+// microseconds.
+func (t TimingEntry) LogFields() map[string]string {
+	return map[string]string{
+		"OPERATION":     t.Operation,
+		"DURATION":      t.Duration.String(),
+		"DURATION_USEC": strconv.FormatInt(int64(t.Duration/time.Microsecond), 10),
+	}
+}
+
+// TimeTrack starts timing an operation and returns a function that logs a
+// TimingEntry for it at the given level when called. It is meant to be
+// deferred:
+//
+//	defer plog.TimeTrack(capnslog.DEBUG, "compaction")()
+func (p *PackageLogger) TimeTrack(l LogLevel, operation string) func() {
+	if !p.enabled(l) {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		p.internalLog(calldepth, l, TimingEntry{operation, time.Since(start)})
+	}
+}
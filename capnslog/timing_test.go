@@ -0,0 +1,60 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimeTrack(t *testing.T) {
+	out := captureOutput(CallerFileLine)
+	defer SetFormatter(NewStringFormatter(ioutil.Discard))
+	p := NewPackageLogger("github.com/coreos/pkg/capnslog", "timing")
+	p.SetLevel(INFO)
+
+	done := p.TimeTrack(INFO, "compaction")
+	line := nextLine()
+	done()
+	want := regexp.MustCompile(fmt.Sprintf(`^2016-01-02T15:04:05Z \[timing_test.go:%d\] timing: compaction took \S+\n$`, line))
+	if !want.MatchString(out.String()) {
+		t.Errorf("want match for %s, got %q", want, out.String())
+	}
+
+	out.Reset()
+	p.TimeTrack(DEBUG, "snapshot")()
+	if out.Len() != 0 {
+		t.Errorf("want nothing logged below the package level, got %q", out.String())
+	}
+}
+
+func TestTimingEntry(t *testing.T) {
+	e := TimingEntry{"compaction", 1500 * time.Microsecond}
+	if want, got := "compaction took 1.5ms", e.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+	want := map[string]string{
+		"OPERATION":     "compaction",
+		"DURATION":      "1.5ms",
+		"DURATION_USEC": "1500",
+	}
+	if got := e.LogFields(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+}
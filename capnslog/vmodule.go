@@ -0,0 +1,143 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capnslog
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type vmoduleRule struct {
+	pattern string
+	level   LogLevel
+}
+
+type vmoduleConfig struct {
+	spec  string
+	rules []vmoduleRule
+	max   LogLevel
+	// cache maps a call site's PC to its vmoduleMatch.
+	cache sync.Map
+}
+
+type vmoduleMatch struct {
+	level LogLevel
+	ok    bool
+}
+
+var vmodule atomic.Value // of *vmoduleConfig
+
+// SetVModule sets per-file log levels in the style of glog's -vmodule flag,
+// easing migration of programs that use glog. spec is a comma-separated list
+// of "pattern=level" settings. A pattern is a filepath.Match glob matched
+// against the name of the source file making the logging call without its
+// ".go" suffix, such as "gzran*", or, if it contains a slash, against the
+// same number of trailing elements of the file's path. The level may be any
+// string accepted by ParseLevel, so glog's verbosity 5 maps to TRACE. For
+// calls from a matching file, the first matching setting replaces the
+// package's log level. An empty spec removes all settings.
+func SetVModule(spec string) error {
+	if spec == "" {
+		vmodule.Store((*vmoduleConfig)(nil))
+		return nil
+	}
+	cfg := &vmoduleConfig{spec: spec, max: CRITICAL}
+	for _, s := range strings.Split(spec, ",") {
+		setting := strings.Split(s, "=")
+		if len(setting) != 2 || setting[0] == "" {
+			return errors.New("oddly structured `pattern=level` option: " + s)
+		}
+		if _, err := filepath.Match(setting[0], ""); err != nil {
+			return err
+		}
+		l, err := ParseLevel(setting[1])
+		if err != nil {
+			return err
+		}
+		cfg.rules = append(cfg.rules, vmoduleRule{setting[0], l})
+		if l > cfg.max {
+			cfg.max = l
+		}
+	}
+	vmodule.Store(cfg)
+	return nil
+}
+
+// VModule returns the spec last passed to SetVModule.
+func VModule() string {
+	if cfg := loadVModule(); cfg != nil {
+		return cfg.spec
+	}
+	return ""
+}
+
+func loadVModule() *vmoduleConfig {
+	cfg, _ := vmodule.Load().(*vmoduleConfig)
+	return cfg
+}
+
+// vmoduleMayEnable reports whether some file could be logging at level l.
+func vmoduleMayEnable(l LogLevel) bool {
+	cfg := loadVModule()
+	return cfg != nil && l <= cfg.max
+}
+
+// vmoduleLevel returns the level set for the source file of the logging call,
+// if any pattern matches it. depth is the depth passed to internalLog.
+func vmoduleLevel(depth int) (LogLevel, bool) {
+	cfg := loadVModule()
+	if cfg == nil {
+		return 0, false
+	}
+	pc, file, _, ok := runtime.Caller(depth + 1)
+	if !ok {
+		return 0, false
+	}
+	if m, ok := cfg.cache.Load(pc); ok {
+		m := m.(vmoduleMatch)
+		return m.level, m.ok
+	}
+	m := cfg.match(file)
+	cfg.cache.Store(pc, m)
+	return m.level, m.ok
+}
+
+func (cfg *vmoduleConfig) match(file string) vmoduleMatch {
+	file = strings.TrimSuffix(file, ".go")
+	base := filepath.Base(file)
+	for _, r := range cfg.rules {
+		name := base
+		if strings.Contains(r.pattern, "/") {
+			name = trailingPath(file, strings.Count(r.pattern, "/")+1)
+		}
+		if ok, _ := filepath.Match(r.pattern, name); ok {
+			return vmoduleMatch{r.level, true}
+		}
+	}
+	return vmoduleMatch{}
+}
+
+// trailingPath returns the last n slash-separated elements of path.
+func trailingPath(path string, n int) string {
+	i := len(path)
+	for ; n > 0 && i >= 0; n-- {
+		i = strings.LastIndex(path[:i], "/")
+	}
+	return path[i+1:]
+}
@@ -0,0 +1,106 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// RegisterFlags registers a flag in fs for each field of the struct cfg
+// points to that has a `flag:"name"` tag, with the usage given in a
+// `desc:"..."` tag and the field's current value as the default. Flags set
+// the fields directly, so cfg holds the parsed values after fs.Parse.
+//
+// Fields may be strings, bools, ints, int64s, uints, uint64s, float64s,
+// time.Durations and []strings, which are parsed as a StringSliceFlag, or of
+// any type whose pointer implements flag.Value, such as the types of this
+// package. IntRangeFlag and StringEnumFlag fields must be set with their
+// constructors first, as their zero values accept nothing. Fields of embedded
+// structs are registered too.
+//
+//	var cfg struct {
+//		Listen  string            `flag:"listen" desc:"address to listen on"`
+//		Workers int               `flag:"workers" desc:"number of workers"`
+//		Pods    flagutil.CIDRFlag `flag:"pod-cidr" desc:"pod network"`
+//	}
+//	cfg.Listen = ":8080"
+//	flagutil.RegisterFlags(fs, &cfg)
+func RegisterFlags(fs *flag.FlagSet, cfg interface{}) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("want a pointer to a struct, got %T", cfg)
+	}
+	return registerStruct(fs, v.Elem())
+}
+
+func registerStruct(fs *flag.FlagSet, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		name, ok := field.Tag.Lookup("flag")
+		if !ok || name == "-" {
+			if field.Anonymous && fv.Kind() == reflect.Struct {
+				if err := registerStruct(fs, fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			return fmt.Errorf("field %s of flag -%s is unexported", field.Name, name)
+		}
+		if err := registerField(fs, fv, name, field.Tag.Get("desc")); err != nil {
+			return fmt.Errorf("field %s: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func registerField(fs *flag.FlagSet, fv reflect.Value, name, usage string) error {
+	ptr := fv.Addr().Interface()
+	switch p := ptr.(type) {
+	case *IntRangeFlag:
+		if p.min == 0 && p.max == 0 {
+			return fmt.Errorf("flag -%s has no range, set it with NewIntRangeFlag", name)
+		}
+	case *StringEnumFlag:
+		if len(p.allowed) == 0 {
+			return fmt.Errorf("flag -%s has no allowed values, set it with NewStringEnumFlag", name)
+		}
+	}
+	if value, ok := ptr.(flag.Value); ok {
+		fs.Var(value, name, usage)
+		return nil
+	}
+
+	if fv.Type() == durationType {
+		p := ptr.(*time.Duration)
+		fs.DurationVar(p, name, *p, usage)
+		return nil
+	}
+	switch p := ptr.(type) {
+	case *string:
+		fs.StringVar(p, name, *p, usage)
+	case *bool:
+		fs.BoolVar(p, name, *p, usage)
+	case *int:
+		fs.IntVar(p, name, *p, usage)
+	case *int64:
+		fs.Int64Var(p, name, *p, usage)
+	case *uint:
+		fs.UintVar(p, name, *p, usage)
+	case *uint64:
+		fs.Uint64Var(p, name, *p, usage)
+	case *float64:
+		fs.Float64Var(p, name, *p, usage)
+	case *[]string:
+		fs.Var((*StringSliceFlag)(p), name, usage)
+	default:
+		return fmt.Errorf("unsupported type %s for flag -%s", fv.Type(), name)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package flagutil
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testCommon struct {
+	Debug bool `flag:"debug" desc:"enable debug logging"`
+}
+
+type testConfig struct {
+	testCommon
+	Listen   string         `flag:"listen" desc:"address to listen on"`
+	Workers  int            `flag:"workers" desc:"number of workers"`
+	Max      int64          `flag:"max"`
+	Count    uint           `flag:"count"`
+	Size     uint64         `flag:"size"`
+	Ratio    float64        `flag:"ratio"`
+	Timeout  time.Duration  `flag:"timeout"`
+	Peers    []string       `flag:"peers"`
+	PodCIDR  CIDRFlag       `flag:"pod-cidr" desc:"pod network"`
+	Labels   KVMapFlag      `flag:"labels"`
+	Level    IntRangeFlag   `flag:"level"`
+	Format   StringEnumFlag `flag:"format"`
+	Ignored  string
+	Excluded string `flag:"-"`
+}
+
+func TestRegisterFlags(t *testing.T) {
+	cfg := testConfig{
+		Listen:  ":8080",
+		Workers: 4,
+		Level:   *NewIntRangeFlag(1, 0, 3),
+		Format:  *NewStringEnumFlag("text", "text", "json"),
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := RegisterFlags(fs, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, want := range map[string]string{"listen": ":8080", "workers": "4", "timeout": "0s"} {
+		if got := fs.Lookup(name).DefValue; got != want {
+			t.Errorf("flag -%s: want default=%q got=%q", name, want, got)
+		}
+	}
+	if want, got := "address to listen on", fs.Lookup("listen").Usage; got != want {
+		t.Errorf("want usage=%q got=%q", want, got)
+	}
+	for _, name := range []string{"Ignored", "ignored", "Excluded", "-"} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("unexpected flag -%s", name)
+		}
+	}
+
+	err := fs.Parse([]string{
+		"-debug", "-listen=:9090", "-workers=8", "-max=-1", "-count=2", "-size=3",
+		"-ratio=0.5", "-timeout=1m", "-peers=a,b", "-pod-cidr=10.2.0.0/16", "-labels=app=web",
+		"-level=3", "-format=json",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Debug || cfg.Listen != ":9090" || cfg.Workers != 8 || cfg.Max != -1 || cfg.Count != 2 ||
+		cfg.Size != 3 || cfg.Ratio != 0.5 || cfg.Timeout != time.Minute {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Peers, []string{"a", "b"}) {
+		t.Errorf("want peers=[a b] got=%v", cfg.Peers)
+	}
+	if want, got := "10.2.0.0/16", cfg.PodCIDR.String(); got != want {
+		t.Errorf("want pod-cidr=%q got=%q", want, got)
+	}
+	if want, got := "app=web", cfg.Labels.String(); got != want {
+		t.Errorf("want labels=%q got=%q", want, got)
+	}
+	if cfg.Level.Value() != 3 || cfg.Format.Value() != "json" {
+		t.Errorf("want level=3 format=json, got level=%d format=%q", cfg.Level.Value(), cfg.Format.Value())
+	}
+}
+
+func TestRegisterFlagsInvalid(t *testing.T) {
+	tests := []interface{}{
+		testConfig{},
+		new(int),
+		&struct {
+			C chan int `flag:"c"`
+		}{},
+		&struct {
+			hidden string `flag:"hidden"`
+		}{},
+		// Zero values that would reject every input.
+		&struct {
+			Level IntRangeFlag `flag:"level"`
+		}{},
+		&struct {
+			Format StringEnumFlag `flag:"format"`
+		}{},
+	}
+
+	for i, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := RegisterFlags(fs, tt); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
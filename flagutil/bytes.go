@@ -0,0 +1,93 @@
+package flagutil
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Base64Flag decodes a base64 string into a []byte, such as a key or token.
+// This type implements the flag.Value interface.
+type Base64Flag struct {
+	val []byte
+
+	// Encoding defaults to base64.StdEncoding.
+	Encoding *base64.Encoding
+	// Len, if non-zero, is the exact number of decoded bytes required.
+	// MinLen, if non-zero, is the least number.
+	Len    int
+	MinLen int
+}
+
+func (f *Base64Flag) Bytes() []byte {
+	return f.val
+}
+
+func (f *Base64Flag) Set(v string) error {
+	b, err := f.encoding().DecodeString(v)
+	if err != nil {
+		return fmt.Errorf("invalid base64: %v", err)
+	}
+	if err := checkLen(b, f.Len, f.MinLen); err != nil {
+		return err
+	}
+	f.val = b
+	return nil
+}
+
+func (f *Base64Flag) String() string {
+	if f.val == nil {
+		return ""
+	}
+	return f.encoding().EncodeToString(f.val)
+}
+
+func (f *Base64Flag) encoding() *base64.Encoding {
+	if f.Encoding == nil {
+		return base64.StdEncoding
+	}
+	return f.Encoding
+}
+
+// HexBytesFlag decodes a hex string into a []byte, such as a key or token.
+// This type implements the flag.Value interface.
+type HexBytesFlag struct {
+	val []byte
+
+	// Len, if non-zero, is the exact number of decoded bytes required.
+	// MinLen, if non-zero, is the least number.
+	Len    int
+	MinLen int
+}
+
+func (f *HexBytesFlag) Bytes() []byte {
+	return f.val
+}
+
+func (f *HexBytesFlag) Set(v string) error {
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return fmt.Errorf("invalid hex: %v", err)
+	}
+	if err := checkLen(b, f.Len, f.MinLen); err != nil {
+		return err
+	}
+	f.val = b
+	return nil
+}
+
+func (f *HexBytesFlag) String() string {
+	return hex.EncodeToString(f.val)
+}
+
+// checkLen checks that b is exactly n bytes long if n is non-zero, and at
+// least min bytes long if min is.
+func checkLen(b []byte, n, min int) error {
+	if n != 0 && len(b) != n {
+		return fmt.Errorf("decoded to %d bytes, want %d", len(b), n)
+	}
+	if min != 0 && len(b) < min {
+		return fmt.Errorf("decoded to %d bytes, want at least %d", len(b), min)
+	}
+	return nil
+}
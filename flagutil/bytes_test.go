@@ -0,0 +1,80 @@
+package flagutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestBase64Flag(t *testing.T) {
+	tests := []struct {
+		f       Base64Flag
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{input: "aGVsbG8=", want: []byte("hello")},
+		{input: "", want: []byte{}},
+		{input: "aGVsbG8", wantErr: true},
+		{input: "not base64!", wantErr: true},
+		{f: Base64Flag{Encoding: base64.RawURLEncoding}, input: "-_8", want: []byte{0xfb, 0xff}},
+		{f: Base64Flag{Encoding: base64.RawURLEncoding}, input: "+/8", wantErr: true},
+		{f: Base64Flag{Len: 5}, input: "aGVsbG8=", want: []byte("hello")},
+		{f: Base64Flag{Len: 4}, input: "aGVsbG8=", wantErr: true},
+		{f: Base64Flag{MinLen: 5}, input: "aGVsbG8=", want: []byte("hello")},
+		{f: Base64Flag{MinLen: 6}, input: "aGVsbG8=", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		err := tt.f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if !bytes.Equal(tt.want, tt.f.Bytes()) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, tt.f.Bytes())
+		}
+		if !tt.wantErr && tt.f.String() != tt.input {
+			t.Errorf("case %d: want=%q got=%q", i, tt.input, tt.f.String())
+		}
+	}
+}
+
+func TestHexBytesFlag(t *testing.T) {
+	tests := []struct {
+		f       HexBytesFlag
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{input: "deadbeef", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{input: "DEADBEEF", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{input: "", want: []byte{}},
+		{input: "abc", wantErr: true},
+		{input: "zz", wantErr: true},
+		{f: HexBytesFlag{Len: 4}, input: "deadbeef", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{f: HexBytesFlag{Len: 32}, input: "deadbeef", wantErr: true},
+		{f: HexBytesFlag{MinLen: 2}, input: "deadbeef", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{f: HexBytesFlag{MinLen: 16}, input: "deadbeef", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		err := tt.f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if !bytes.Equal(tt.want, tt.f.Bytes()) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, tt.f.Bytes())
+		}
+	}
+
+	f := HexBytesFlag{}
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	f.Set("DEADBEEF")
+	if want, got := "deadbeef", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
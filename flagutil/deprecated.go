@@ -0,0 +1,83 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+)
+
+// deprecatedFlag sets the flag it replaces, warning once.
+type deprecatedFlag struct {
+	flag.Value
+	fs          *flag.FlagSet
+	name        string
+	replacement string
+	once        sync.Once
+}
+
+func (d *deprecatedFlag) Set(v string) error {
+	d.once.Do(func() {
+		fmt.Fprintf(d.fs.Output(), "WARNING: flag -%s is deprecated, use -%s instead\n", d.name, d.replacement)
+	})
+	// Setting the flag through fs, not its Value, marks it as set, so that
+	// SetFlagsFromEnv and the like do not override it.
+	return d.fs.Set(d.replacement, v)
+}
+
+// IsBoolFlag lets an alias of a boolean flag be given without a value.
+func (d *deprecatedFlag) IsBoolFlag() bool {
+	b, ok := d.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// DeprecatedAlias registers oldName as a deprecated alias of the flag newName,
+// which must already be registered. Setting the alias sets newName, with a
+// warning written to the flag set's output the first time, and counts as
+// setting newName for fs.Visit. The alias is hidden from PrintDefaults and
+// PrintDefaultsWithEnv, but not from fs.PrintDefaults, so fs.Usage should be
+// set to use one of them.
+func DeprecatedAlias(fs *flag.FlagSet, oldName, newName string) error {
+	f := fs.Lookup(newName)
+	if f == nil {
+		return fmt.Errorf("no flag -%s to alias", newName)
+	}
+	d := &deprecatedFlag{
+		Value:       f.Value,
+		fs:          fs,
+		name:        oldName,
+		replacement: newName,
+	}
+	fs.Var(d, oldName, fmt.Sprintf("deprecated, use -%s", newName))
+	fs.Lookup(oldName).DefValue = f.DefValue
+	return nil
+}
+
+// Hidden reports whether f is left out of PrintDefaults, as deprecated
+// aliases are.
+func Hidden(f *flag.Flag) bool {
+	_, ok := f.Value.(*deprecatedFlag)
+	return ok
+}
+
+// PrintDefaults prints the usage of the flags of fs as fs.PrintDefaults does,
+// leaving out hidden flags.
+func PrintDefaults(fs *flag.FlagSet) {
+	printDefaults(fs, func(f *flag.Flag) string {
+		return f.Usage
+	})
+}
+
+// printDefaults prints the usage of the visible flags of fs, with the usage
+// text of each returned by usage.
+func printDefaults(fs *flag.FlagSet, usage func(*flag.Flag) string) {
+	visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	visible.SetOutput(fs.Output())
+	fs.VisitAll(func(f *flag.Flag) {
+		if Hidden(f) {
+			return
+		}
+		visible.Var(f.Value, f.Name, usage(f))
+		visible.Lookup(f.Name).DefValue = f.DefValue
+	})
+	visible.PrintDefaults()
+}
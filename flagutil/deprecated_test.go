@@ -0,0 +1,81 @@
+package flagutil
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeprecatedAlias(t *testing.T) {
+	out := &bytes.Buffer{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(out)
+	addr := fs.String("bind-address", "0.0.0.0", "address to listen on")
+	debug := fs.Bool("debug", false, "enable debug logging")
+	if err := DeprecatedAlias(fs, "listen", "bind-address"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := DeprecatedAlias(fs, "verbose", "debug"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := DeprecatedAlias(fs, "old", "missing"); err == nil {
+		t.Errorf("expected error aliasing a missing flag")
+	}
+
+	if err := fs.Parse([]string{"-listen=127.0.0.1", "-listen=10.0.0.1", "-verbose"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *addr != "10.0.0.1" || !*debug {
+		t.Errorf("want bind-address=10.0.0.1 debug=true, got bind-address=%s debug=%t", *addr, *debug)
+	}
+	want := "WARNING: flag -listen is deprecated, use -bind-address instead\n" +
+		"WARNING: flag -verbose is deprecated, use -debug instead\n"
+	if got := out.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+
+	out.Reset()
+	PrintDefaults(fs)
+	usage := out.String()
+	for _, s := range []string{"-bind-address", "address to listen on", `(default "0.0.0.0")`, "-debug"} {
+		if !strings.Contains(usage, s) {
+			t.Errorf("usage does not contain %q:\n%s", s, usage)
+		}
+	}
+	for _, s := range []string{"-listen", "-verbose"} {
+		if strings.Contains(usage, s) {
+			t.Errorf("usage contains hidden %q:\n%s", s, usage)
+		}
+	}
+}
+
+func TestDeprecatedAliasPrecedence(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	name := fs.String("new-name", "default", "")
+	if err := DeprecatedAlias(fs, "old-name", "new-name"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fs.Parse([]string{"-old-name=cmdline"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["new-name"] {
+		t.Errorf("want -new-name visited as set, got %v", set)
+	}
+
+	os.Setenv("P_NEW_NAME", "env")
+	defer os.Unsetenv("P_NEW_NAME")
+	if err := SetFlagsFromEnv(fs, "P"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *name != "cmdline" {
+		t.Errorf("want=%q got=%q", "cmdline", *name)
+	}
+}
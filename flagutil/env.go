@@ -20,7 +20,7 @@ func SetFlagsFromEnv(fs *flag.FlagSet, prefix string) (err error) {
 	})
 	fs.VisitAll(func(f *flag.Flag) {
 		if !alreadySet[f.Name] {
-			key := prefix + "_" + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+			key := envKey(prefix, f.Name)
 			val := os.Getenv(key)
 			if val != "" {
 				if serr := fs.Set(f.Name, val); serr != nil {
@@ -31,3 +31,9 @@ func SetFlagsFromEnv(fs *flag.FlagSet, prefix string) (err error) {
 	})
 	return err
 }
+
+// envKey returns the environment variable SetFlagsFromEnv reads the flag name
+// from.
+func envKey(prefix, name string) string {
+	return prefix + "_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
@@ -0,0 +1,146 @@
+package flagutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntRangeFlag accepts an integer between bounds, inclusive. Create it with
+// NewIntRangeFlag. This type implements the flag.Value interface.
+type IntRangeFlag struct {
+	min, max int
+	val      int
+}
+
+// NewIntRangeFlag returns an IntRangeFlag accepting values from min to max,
+// set to def.
+func NewIntRangeFlag(def, min, max int) *IntRangeFlag {
+	return &IntRangeFlag{min: min, max: max, val: def}
+}
+
+func (f *IntRangeFlag) Value() int {
+	return f.val
+}
+
+func (f *IntRangeFlag) Set(v string) error {
+	n, err := strconv.ParseInt(v, 0, strconv.IntSize)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", v)
+	}
+	if int(n) < f.min || int(n) > f.max {
+		return fmt.Errorf("%d is out of range [%d, %d]", n, f.min, f.max)
+	}
+	f.val = int(n)
+	return nil
+}
+
+func (f *IntRangeFlag) String() string {
+	return strconv.Itoa(f.val)
+}
+
+// Usage appends the range to the usage text desc, for registering the flag.
+func (f *IntRangeFlag) Usage(desc string) string {
+	return fmt.Sprintf("%s (range [%d, %d])", desc, f.min, f.max)
+}
+
+// Float64RangeFlag accepts a number between bounds, inclusive. Create it with
+// NewFloat64RangeFlag. This type implements the flag.Value interface.
+type Float64RangeFlag struct {
+	min, max float64
+	val      float64
+}
+
+// NewFloat64RangeFlag returns a Float64RangeFlag accepting values from min to
+// max, set to def.
+func NewFloat64RangeFlag(def, min, max float64) *Float64RangeFlag {
+	return &Float64RangeFlag{min: min, max: max, val: def}
+}
+
+func (f *Float64RangeFlag) Value() float64 {
+	return f.val
+}
+
+func (f *Float64RangeFlag) Set(v string) error {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	// Written so that NaN is rejected.
+	if !(n >= f.min && n <= f.max) {
+		return fmt.Errorf("%v is out of range [%v, %v]", n, f.min, f.max)
+	}
+	f.val = n
+	return nil
+}
+
+func (f *Float64RangeFlag) String() string {
+	return strconv.FormatFloat(f.val, 'g', -1, 64)
+}
+
+// Usage appends the range to the usage text desc, for registering the flag.
+func (f *Float64RangeFlag) Usage(desc string) string {
+	return fmt.Sprintf("%s (range [%v, %v])", desc, f.min, f.max)
+}
+
+// DurationFlag accepts a time.ParseDuration string between optional bounds,
+// inclusive. Create it with NewDurationFlag. This type implements the
+// flag.Value interface.
+type DurationFlag struct {
+	min, max time.Duration
+	val      time.Duration
+
+	// Positive rejects zero and negative durations.
+	Positive bool
+}
+
+// NewDurationFlag returns a DurationFlag set to def, accepting values from
+// min to max. A zero min or max leaves that end unbounded.
+func NewDurationFlag(def, min, max time.Duration) *DurationFlag {
+	return &DurationFlag{min: min, max: max, val: def}
+}
+
+func (f *DurationFlag) Duration() time.Duration {
+	return f.val
+}
+
+func (f *DurationFlag) Set(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	switch {
+	case f.Positive && d <= 0:
+		return fmt.Errorf("%v is not positive", d)
+	case f.min != 0 && d < f.min:
+		return fmt.Errorf("%v is less than %v", d, f.min)
+	case f.max != 0 && d > f.max:
+		return fmt.Errorf("%v is greater than %v", d, f.max)
+	}
+	f.val = d
+	return nil
+}
+
+func (f *DurationFlag) String() string {
+	return f.val.String()
+}
+
+// Usage appends the constraints to the usage text desc, for registering the
+// flag.
+func (f *DurationFlag) Usage(desc string) string {
+	var c []string
+	if f.Positive {
+		c = append(c, "positive")
+	}
+	if f.min != 0 {
+		c = append(c, fmt.Sprintf(">= %v", f.min))
+	}
+	if f.max != 0 {
+		c = append(c, fmt.Sprintf("<= %v", f.max))
+	}
+	if len(c) == 0 {
+		return desc
+	}
+	return fmt.Sprintf("%s (%s)", desc, strings.Join(c, ", "))
+}
@@ -0,0 +1,141 @@
+package flagutil
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestIntRangeFlag(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{input: "1", want: 1},
+		{input: "65535", want: 65535},
+		{input: "0x50", want: 80},
+		{input: "0", want: 8080, wantErr: true},
+		{input: "65536", want: 8080, wantErr: true},
+		{input: "-1", want: 8080, wantErr: true},
+		{input: "http", want: 8080, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := NewIntRangeFlag(8080, 1, 65535)
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+		}
+		if f.Value() != tt.want {
+			t.Errorf("case %d: want=%d got=%d", i, tt.want, f.Value())
+		}
+	}
+}
+
+func TestFloat64RangeFlag(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "0.25", want: 0.25},
+		{input: "1", want: 1},
+		{input: "1.01", want: 0.5, wantErr: true},
+		{input: "-0.1", want: 0.5, wantErr: true},
+		{input: "NaN", want: 0.5, wantErr: true},
+		{input: "half", want: 0.5, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := NewFloat64RangeFlag(0.5, 0, 1)
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+		}
+		if f.Value() != tt.want {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, f.Value())
+		}
+	}
+}
+
+func TestRangeFlagUsage(t *testing.T) {
+	port := NewIntRangeFlag(8080, 1, 65535)
+	ratio := NewFloat64RangeFlag(0.5, 0, 1)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(port, "port", port.Usage("port to listen on"))
+	fs.Var(ratio, "ratio", ratio.Usage("sampling ratio"))
+
+	for name, want := range map[string]string{
+		"port":  "port to listen on (range [1, 65535])",
+		"ratio": "sampling ratio (range [0, 1])",
+	} {
+		if got := fs.Lookup(name).Usage; got != want {
+			t.Errorf("flag -%s: want=%q got=%q", name, want, got)
+		}
+	}
+	if want, got := "8080", fs.Lookup("port").DefValue; got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+	if err := fs.Parse([]string{"-port=0"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestDurationFlag(t *testing.T) {
+	tests := []struct {
+		min, max time.Duration
+		positive bool
+		input    string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{input: "-5s", want: -5 * time.Second},
+		{input: "0", want: 0},
+		{positive: true, input: "0", want: time.Minute, wantErr: true},
+		{positive: true, input: "-1s", want: time.Minute, wantErr: true},
+		{positive: true, input: "1ns", want: time.Nanosecond},
+		{min: time.Second, max: 24 * time.Hour, input: "1s", want: time.Second},
+		{min: time.Second, max: 24 * time.Hour, input: "24h", want: 24 * time.Hour},
+		{min: time.Second, max: 24 * time.Hour, input: "999ms", want: time.Minute, wantErr: true},
+		{min: time.Second, max: 24 * time.Hour, input: "25h", want: time.Minute, wantErr: true},
+		{min: time.Second, input: "1000h", want: 1000 * time.Hour},
+		{input: "5", want: time.Minute, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := NewDurationFlag(time.Minute, tt.min, tt.max)
+		f.Positive = tt.positive
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+		}
+		if f.Duration() != tt.want {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, f.Duration())
+		}
+	}
+}
+
+func TestDurationFlagUsage(t *testing.T) {
+	tests := []struct {
+		f    *DurationFlag
+		want string
+	}{
+		{NewDurationFlag(time.Minute, 0, 0), "interval"},
+		{NewDurationFlag(time.Minute, time.Second, 24*time.Hour), "interval (>= 1s, <= 24h0m0s)"},
+		{NewDurationFlag(time.Minute, 0, time.Hour), "interval (<= 1h0m0s)"},
+		{&DurationFlag{Positive: true}, "interval (positive)"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.f.Usage("interval"); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+	if want, got := "1m0s", NewDurationFlag(time.Minute, 0, 0).String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
@@ -0,0 +1,118 @@
+package flagutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var tlsVersions = []struct {
+	name    string
+	version uint16
+}{
+	{"TLS1.0", tls.VersionTLS10},
+	{"TLS1.1", tls.VersionTLS11},
+	{"TLS1.2", tls.VersionTLS12},
+	{"TLS1.3", tls.VersionTLS13},
+}
+
+// TLSVersionFlag parses a TLS version, such as "TLS1.2", into a
+// crypto/tls version constant for tls.Config.MinVersion or MaxVersion. The
+// "TLS" prefix is optional and case is ignored. This type implements the
+// flag.Value interface.
+type TLSVersionFlag struct {
+	val uint16
+}
+
+func (f *TLSVersionFlag) Version() uint16 {
+	return f.val
+}
+
+func (f *TLSVersionFlag) Set(v string) error {
+	for _, tv := range tlsVersions {
+		if strings.EqualFold(v, tv.name) || v == strings.TrimPrefix(tv.name, "TLS") {
+			f.val = tv.version
+			return nil
+		}
+	}
+	names := make([]string, len(tlsVersions))
+	for i, tv := range tlsVersions {
+		names[i] = tv.name
+	}
+	return fmt.Errorf("unknown TLS version %q, must be one of %s", v, strings.Join(names, ", "))
+}
+
+func (f *TLSVersionFlag) String() string {
+	for _, tv := range tlsVersions {
+		if f.val == tv.version {
+			return tv.name
+		}
+	}
+	return ""
+}
+
+// CipherSuitesFlag parses a comma-delimited list of TLS cipher suites into
+// IDs for tls.Config.CipherSuites. Suites are given by their IANA names, such
+// as "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", or as hexadecimal IDs. This
+// type implements the flag.Value interface.
+type CipherSuitesFlag struct {
+	// AllowInsecure accepts suites with known security issues, which are
+	// otherwise rejected.
+	AllowInsecure bool
+
+	val []uint16
+}
+
+func (f *CipherSuitesFlag) CipherSuites() []uint16 {
+	return f.val
+}
+
+func (f *CipherSuitesFlag) Set(v string) error {
+	var ids []uint16
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		cs, insecure := lookupCipherSuite(s)
+		if cs == nil {
+			return fmt.Errorf("unknown cipher suite %q", s)
+		}
+		if insecure && !f.AllowInsecure {
+			return fmt.Errorf("cipher suite %s is insecure", cs.Name)
+		}
+		ids = append(ids, cs.ID)
+	}
+	f.val = ids
+	return nil
+}
+
+func (f *CipherSuitesFlag) String() string {
+	names := make([]string, len(f.val))
+	for i, id := range f.val {
+		names[i] = tls.CipherSuiteName(id)
+	}
+	return strings.Join(names, ",")
+}
+
+// lookupCipherSuite finds a cipher suite by name or hexadecimal ID, and
+// reports whether it is insecure.
+func lookupCipherSuite(s string) (*tls.CipherSuite, bool) {
+	id, err := strconv.ParseUint(s, 0, 16)
+	byID := err == nil && strings.HasPrefix(strings.ToLower(s), "0x")
+	match := func(cs *tls.CipherSuite) bool {
+		if byID {
+			return cs.ID == uint16(id)
+		}
+		return cs.Name == s
+	}
+	for _, cs := range tls.CipherSuites() {
+		if match(cs) {
+			return cs, false
+		}
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		if match(cs) {
+			return cs, true
+		}
+	}
+	return nil, false
+}
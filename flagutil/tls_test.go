@@ -0,0 +1,88 @@
+package flagutil
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestTLSVersionFlag(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint16
+		wantErr bool
+	}{
+		{input: "TLS1.2", want: tls.VersionTLS12},
+		{input: "tls1.3", want: tls.VersionTLS13},
+		{input: "1.0", want: tls.VersionTLS10},
+		{input: "SSL3.0", wantErr: true},
+		{input: "TLS1.4", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		var f TLSVersionFlag
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if f.Version() != tt.want {
+			t.Errorf("case %d: want=%x got=%x", i, tt.want, f.Version())
+		}
+	}
+
+	var f TLSVersionFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	f.Set("tls1.2")
+	if want, got := "TLS1.2", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
+
+func TestCipherSuitesFlag(t *testing.T) {
+	tests := []struct {
+		allowInsecure bool
+		input         string
+		want          []uint16
+		wantErr       bool
+	}{
+		{
+			input: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		},
+		{
+			input: "0xc02f",
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+		{input: "TLS_RSA_WITH_RC4_128_SHA", wantErr: true},
+		{
+			allowInsecure: true,
+			input:         "TLS_RSA_WITH_RC4_128_SHA",
+			want:          []uint16{tls.TLS_RSA_WITH_RC4_128_SHA},
+		},
+		{input: "TLS_BOGUS", wantErr: true},
+		{input: "49199", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := CipherSuitesFlag{AllowInsecure: tt.allowInsecure}
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.CipherSuites(), tt.want) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, f.CipherSuites())
+		}
+	}
+
+	var f CipherSuitesFlag
+	f.Set("0xc02f,TLS_AES_128_GCM_SHA256")
+	if want, got := "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_AES_128_GCM_SHA256", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
@@ -3,8 +3,13 @@ package flagutil
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // IPv4Flag parses a string into a net.IP after asserting that it
@@ -42,3 +47,377 @@ func (ss *StringSliceFlag) Set(v string) error {
 	*ss = strings.Split(v, ",")
 	return nil
 }
+
+// CIDRFlag parses a string in CIDR notation, such as "10.2.0.0/16", into a
+// *net.IPNet. This type implements the flag.Value interface.
+type CIDRFlag struct {
+	val *net.IPNet
+}
+
+func (f *CIDRFlag) IPNet() *net.IPNet {
+	return f.val
+}
+
+func (f *CIDRFlag) Set(v string) error {
+	ipnet, err := parseCIDR(v)
+	if err != nil {
+		return err
+	}
+	f.val = ipnet
+	return nil
+}
+
+func (f *CIDRFlag) String() string {
+	if f.val == nil {
+		return ""
+	}
+	return f.val.String()
+}
+
+// CIDRSliceFlag parses a comma-delimited list of networks in CIDR notation
+// into a []*net.IPNet. Like StringSliceFlag, each use of the flag replaces the
+// list, and an empty value clears it. This type implements the flag.Value
+// interface.
+type CIDRSliceFlag []*net.IPNet
+
+func (cs *CIDRSliceFlag) String() string {
+	strs := make([]string, len(*cs))
+	for i, ipnet := range *cs {
+		strs[i] = ipnet.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+func (cs *CIDRSliceFlag) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		*cs = nil
+		return nil
+	}
+	var nets []*net.IPNet
+	for _, s := range strings.Split(v, ",") {
+		ipnet, err := parseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		nets = append(nets, ipnet)
+	}
+	*cs = nets
+	return nil
+}
+
+// parseCIDR parses a network in CIDR notation. The address must be that of
+// the network, without host bits set, so that mistyped masks are caught.
+func parseCIDR(v string) (*net.IPNet, error) {
+	ip, ipnet, err := net.ParseCIDR(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", v)
+	}
+	if !ip.Equal(ipnet.IP) {
+		return nil, fmt.Errorf("invalid CIDR %q: host bits set, the network is %s", v, ipnet)
+	}
+	return ipnet, nil
+}
+
+// IPSliceFlag parses a comma-delimited list of IP addresses into a []net.IP.
+// Like StringSliceFlag, each use of the flag replaces the list, and an empty
+// value clears it. This type implements the flag.Value interface.
+type IPSliceFlag []net.IP
+
+func (is *IPSliceFlag) String() string {
+	strs := make([]string, len(*is))
+	for i, ip := range *is {
+		strs[i] = ip.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+func (is *IPSliceFlag) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		*is = nil
+		return nil
+	}
+	var ips []net.IP
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %q", s)
+		}
+		ips = append(ips, ip)
+	}
+	*is = ips
+	return nil
+}
+
+// HostPortSliceFlag parses a comma-delimited list of host:port addresses,
+// such as "10.0.0.1:2379,[fd00::1]:2379", into a []string. Like
+// StringSliceFlag, each use of the flag replaces the list, and an empty value
+// clears it. This type implements the flag.Value interface.
+type HostPortSliceFlag []string
+
+func (hs *HostPortSliceFlag) String() string {
+	return strings.Join(*hs, ",")
+}
+
+func (hs *HostPortSliceFlag) Set(v string) error {
+	if strings.TrimSpace(v) == "" {
+		*hs = nil
+		return nil
+	}
+	var addrs []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		host, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", s, err)
+		}
+		if host == "" {
+			return fmt.Errorf("invalid address %q: missing host", s)
+		}
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return fmt.Errorf("invalid address %q: invalid port %q", s, port)
+		}
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+	*hs = addrs
+	return nil
+}
+
+// DuplicateKeyPolicy decides what a KVMapFlag does with a key that is given
+// more than once.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyOverwrite keeps the last value given for a key.
+	DuplicateKeyOverwrite DuplicateKeyPolicy = iota
+	// DuplicateKeyKeepFirst keeps the first value given for a key.
+	DuplicateKeyKeepFirst
+	// DuplicateKeyError rejects a key given more than once.
+	DuplicateKeyError
+)
+
+// KVMapFlag parses a comma-delimited list of key=value pairs, such as
+// "app=web,tier=frontend", into a map[string]string. The flag may be
+// repeated, each use adding to the map. This type implements the flag.Value
+// interface.
+type KVMapFlag struct {
+	// Duplicates decides how keys given more than once are handled.
+	Duplicates DuplicateKeyPolicy
+
+	val map[string]string
+}
+
+func (f *KVMapFlag) Map() map[string]string {
+	return f.val
+}
+
+func (f *KVMapFlag) Set(v string) error {
+	pairs := make(map[string]string)
+	var keys []string
+	for _, kv := range strings.Split(v, ",") {
+		pair := strings.SplitN(kv, "=", 2)
+		key := strings.TrimSpace(pair[0])
+		if len(pair) != 2 || key == "" {
+			return fmt.Errorf("invalid key=value pair %q", kv)
+		}
+		if _, ok := pairs[key]; !ok {
+			keys = append(keys, key)
+		} else if f.Duplicates == DuplicateKeyError {
+			return fmt.Errorf("duplicate key %q", key)
+		} else if f.Duplicates == DuplicateKeyKeepFirst {
+			continue
+		}
+		pairs[key] = strings.TrimSpace(pair[1])
+	}
+
+	if f.Duplicates == DuplicateKeyError {
+		for _, key := range keys {
+			if _, ok := f.val[key]; ok {
+				return fmt.Errorf("duplicate key %q", key)
+			}
+		}
+	}
+	if f.val == nil {
+		f.val = make(map[string]string)
+	}
+	for _, key := range keys {
+		if _, ok := f.val[key]; ok && f.Duplicates == DuplicateKeyKeepFirst {
+			continue
+		}
+		f.val[key] = pairs[key]
+	}
+	return nil
+}
+
+func (f *KVMapFlag) String() string {
+	keys := make([]string, 0, len(f.val))
+	for key := range f.val {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		strs[i] = key + "=" + f.val[key]
+	}
+	return strings.Join(strs, ",")
+}
+
+// TimeFlag parses an RFC 3339 timestamp, such as "2016-01-02T15:04:05Z",
+// into a time.Time. This type implements the flag.Value interface.
+type TimeFlag struct {
+	// Layouts are additional time.Parse layouts tried, in order, when the
+	// value is not in RFC 3339 format.
+	Layouts []string
+
+	val time.Time
+}
+
+func (f *TimeFlag) Time() time.Time {
+	return f.val
+}
+
+func (f *TimeFlag) Set(v string) error {
+	for _, layout := range append([]string{time.RFC3339Nano}, f.Layouts...) {
+		if t, err := time.Parse(layout, v); err == nil {
+			f.val = t
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q, want RFC 3339 format such as %q", v, time.RFC3339)
+}
+
+func (f *TimeFlag) String() string {
+	if f.val.IsZero() {
+		return ""
+	}
+	return f.val.Format(time.RFC3339Nano)
+}
+
+// StringEnumFlag accepts one of a fixed set of strings. Create it with
+// NewStringEnumFlag. This type implements the flag.Value interface.
+type StringEnumFlag struct {
+	// CaseInsensitive makes Set accept the allowed values in any case. The
+	// value is stored as it was given to NewStringEnumFlag.
+	CaseInsensitive bool
+
+	allowed []string
+	val     string
+}
+
+// NewStringEnumFlag returns a StringEnumFlag accepting the allowed values,
+// set to def.
+func NewStringEnumFlag(def string, allowed ...string) *StringEnumFlag {
+	return &StringEnumFlag{allowed: allowed, val: def}
+}
+
+func (f *StringEnumFlag) Value() string {
+	return f.val
+}
+
+func (f *StringEnumFlag) Set(v string) error {
+	for _, a := range f.allowed {
+		if v == a || (f.CaseInsensitive && strings.EqualFold(v, a)) {
+			f.val = a
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(f.allowed, ", "))
+}
+
+func (f *StringEnumFlag) String() string {
+	return f.val
+}
+
+// Usage appends the allowed values to the usage text desc, for registering
+// the flag:
+//
+//	format := flagutil.NewStringEnumFlag("text", "text", "json")
+//	fs.Var(format, "log-format", format.Usage("log output format"))
+func (f *StringEnumFlag) Usage(desc string) string {
+	return fmt.Sprintf("%s (one of: %s)", desc, strings.Join(f.allowed, ", "))
+}
+
+// FilePathFlag accepts the path of an existing file, so that a missing file
+// is reported when flags are parsed. This type implements the flag.Value
+// interface.
+type FilePathFlag struct {
+	// Readable additionally requires that the file can be opened for
+	// reading.
+	Readable bool
+
+	val string
+}
+
+func (f *FilePathFlag) Path() string {
+	return f.val
+}
+
+func (f *FilePathFlag) Set(v string) error {
+	if err := checkPath(v, false, f.Readable); err != nil {
+		return err
+	}
+	f.val = v
+	return nil
+}
+
+func (f *FilePathFlag) String() string {
+	return f.val
+}
+
+// DirPathFlag accepts the path of an existing directory. This type
+// implements the flag.Value interface.
+type DirPathFlag struct {
+	// Readable additionally requires that the directory can be listed.
+	Readable bool
+
+	val string
+}
+
+func (f *DirPathFlag) Path() string {
+	return f.val
+}
+
+func (f *DirPathFlag) Set(v string) error {
+	if err := checkPath(v, true, f.Readable); err != nil {
+		return err
+	}
+	f.val = v
+	return nil
+}
+
+func (f *DirPathFlag) String() string {
+	return f.val
+}
+
+func checkPath(path string, dir, readable bool) error {
+	kind := "file"
+	if dir {
+		kind = "directory"
+	}
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s %q does not exist", kind, path)
+	} else if err != nil {
+		return err
+	}
+	if fi.IsDir() != dir {
+		return fmt.Errorf("%q is not a %s", path, kind)
+	}
+	if readable {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s %q is not readable: %v", kind, path, err)
+		}
+		if dir {
+			_, err = f.Readdirnames(1)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s %q is not readable: %v", kind, path, err)
+		}
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package flagutil
 
 import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestIPv4FlagSetInvalidArgument(t *testing.T) {
@@ -55,3 +61,363 @@ func TestStringSliceFlag(t *testing.T) {
 		}
 	}
 }
+
+func TestCIDRFlag(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "10.2.0.0/16", want: "10.2.0.0/16"},
+		{input: "0.0.0.0/0", want: "0.0.0.0/0"},
+		{input: "fd00::/8", want: "fd00::/8"},
+		{input: "10.2.0.1/32", want: "10.2.0.1/32"},
+		{input: "10.2.0.1/16", wantErr: true},
+		{input: "10.2.0.0/33", wantErr: true},
+		{input: "fd00::/129", wantErr: true},
+		{input: "10.2.0.0", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		var f CIDRFlag
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if got := f.String(); got != tt.want {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestCIDRSliceFlag(t *testing.T) {
+	var f CIDRSliceFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	// Each use replaces the list.
+	for _, v := range []string{"172.16.0.0/12", "10.0.0.0/8, 192.168.0.0/16,fd00::/8"} {
+		if err := f.Set(v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if want, got := "10.0.0.0/8,192.168.0.0/16,fd00::/8", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+	if !f[1].Contains(net.ParseIP("192.168.1.1")) {
+		t.Errorf("%v does not contain 192.168.1.1", f[1])
+	}
+
+	if err := f.Set("10.0.0.0/8,bogus"); err == nil {
+		t.Errorf("expected error")
+	}
+	if len(f) != 3 {
+		t.Errorf("invalid list changed the flag: %v", f)
+	}
+
+	// The value String returns, including that of an empty list, sets the
+	// same list, as a config reload resetting the flag relies on.
+	if err := f.Set(f.String()); err != nil || len(f) != 3 {
+		t.Errorf("setting %q: err=%v list=%v", f.String(), err, f)
+	}
+	if err := f.Set(""); err != nil || len(f) != 0 {
+		t.Errorf("empty value did not clear the list: err=%v list=%v", err, f)
+	}
+}
+
+func TestIPSliceFlag(t *testing.T) {
+	var f IPSliceFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	// Each use replaces the list.
+	for _, v := range []string{"10.0.0.9", "10.0.0.1, 10.0.0.2,FD00::1"} {
+		if err := f.Set(v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if want, got := "10.0.0.1,10.0.0.2,fd00::1", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+
+	for _, v := range []string{"10.0.0.3,bogus", "10.0.0.3,", "10.0.0.0/8", "10.0.0.1:80"} {
+		if err := f.Set(v); err == nil {
+			t.Errorf("%q: expected error", v)
+		}
+	}
+	if len(f) != 3 {
+		t.Errorf("invalid list changed the flag: %v", f)
+	}
+
+	if err := f.Set(f.String()); err != nil || len(f) != 3 {
+		t.Errorf("setting %q: err=%v list=%v", f.String(), err, f)
+	}
+	if err := f.Set(""); err != nil || len(f) != 0 {
+		t.Errorf("empty value did not clear the list: err=%v list=%v", err, f)
+	}
+}
+
+func TestHostPortSliceFlag(t *testing.T) {
+	var f HostPortSliceFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	// Each use replaces the list.
+	for _, v := range []string{"10.0.0.9:2379", "10.0.0.1:2379, etcd.local:2379,[fd00::1]:2379"} {
+		if err := f.Set(v); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if want, got := "10.0.0.1:2379,etcd.local:2379,[fd00::1]:2379", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+
+	for _, v := range []string{
+		"10.0.0.1:2379,bogus",
+		"10.0.0.1",
+		":2379",
+		"10.0.0.1:0",
+		"10.0.0.1:65536",
+		"10.0.0.1:http",
+		"fd00::1:2379",
+	} {
+		if err := f.Set(v); err == nil {
+			t.Errorf("%q: expected error", v)
+		}
+	}
+	if len(f) != 3 {
+		t.Errorf("invalid list changed the flag: %v", f)
+	}
+
+	if err := f.Set(f.String()); err != nil || len(f) != 3 {
+		t.Errorf("setting %q: err=%v list=%v", f.String(), err, f)
+	}
+	if err := f.Set(""); err != nil || len(f) != 0 {
+		t.Errorf("empty value did not clear the list: err=%v list=%v", err, f)
+	}
+}
+
+func TestKVMapFlag(t *testing.T) {
+	tests := []struct {
+		policy  DuplicateKeyPolicy
+		inputs  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			inputs: []string{"app=web, tier=frontend", "empty="},
+			want:   map[string]string{"app": "web", "tier": "frontend", "empty": ""},
+		},
+		{
+			inputs: []string{"a=b=c"},
+			want:   map[string]string{"a": "b=c"},
+		},
+		{
+			policy: DuplicateKeyOverwrite,
+			inputs: []string{"a=1,a=2", "a=3"},
+			want:   map[string]string{"a": "3"},
+		},
+		{
+			policy: DuplicateKeyKeepFirst,
+			inputs: []string{"a=1,a=2", "a=3,b=4"},
+			want:   map[string]string{"a": "1", "b": "4"},
+		},
+		{
+			policy:  DuplicateKeyError,
+			inputs:  []string{"a=1,a=2"},
+			wantErr: true,
+		},
+		{
+			policy:  DuplicateKeyError,
+			inputs:  []string{"a=1", "b=2,a=3"},
+			want:    map[string]string{"a": "1"},
+			wantErr: true,
+		},
+		{inputs: []string{"novalue"}, wantErr: true},
+		{inputs: []string{"=value"}, wantErr: true},
+		{inputs: []string{"a=1,"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := KVMapFlag{Duplicates: tt.policy}
+		var err error
+		for _, in := range tt.inputs {
+			if err = f.Set(in); err != nil {
+				break
+			}
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+		}
+		if len(tt.want) == 0 && len(f.Map()) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(tt.want, f.Map()) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, f.Map())
+		}
+	}
+}
+
+func TestKVMapFlagString(t *testing.T) {
+	var f KVMapFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	f.Set("b=2,a=1")
+	if want, got := "a=1,b=2", f.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
+
+func TestTimeFlag(t *testing.T) {
+	tests := []struct {
+		layouts []string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "2016-01-02T15:04:05Z", want: time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{input: "2016-01-02T15:04:05.5+01:00", want: time.Date(2016, 1, 2, 14, 4, 5, 5e8, time.UTC)},
+		{input: "2016-01-02", wantErr: true},
+		{layouts: []string{"2006-01-02"}, input: "2016-01-02", want: time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{layouts: []string{"2006-01-02"}, input: "yesterday", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := TimeFlag{Layouts: tt.layouts}
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		if !f.Time().Equal(tt.want) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, f.Time())
+		}
+	}
+}
+
+func TestTimeFlagString(t *testing.T) {
+	var f TimeFlag
+	if got := f.String(); got != "" {
+		t.Errorf("want=%q got=%q", "", got)
+	}
+	in := "2016-01-02T15:04:05.123+01:00"
+	if err := f.Set(in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := f.String(); got != in {
+		t.Errorf("want=%q got=%q", in, got)
+	}
+}
+
+func TestStringEnumFlag(t *testing.T) {
+	tests := []struct {
+		caseInsensitive bool
+		input           string
+		want            string
+		wantErr         bool
+	}{
+		{input: "json", want: "json"},
+		{input: "JSON", want: "text", wantErr: true},
+		{caseInsensitive: true, input: "JSON", want: "json"},
+		{caseInsensitive: true, input: "yaml", want: "text", wantErr: true},
+		{input: "", want: "text", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		f := NewStringEnumFlag("text", "text", "json")
+		f.CaseInsensitive = tt.caseInsensitive
+		err := f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+		}
+		if got := f.Value(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestStringEnumFlagUsage(t *testing.T) {
+	f := NewStringEnumFlag("text", "text", "json")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(f, "log-format", f.Usage("log output format"))
+
+	if want, got := "log output format (one of: text, json)", fs.Lookup("log-format").Usage; got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+	if want, got := "text", fs.Lookup("log-format").DefValue; got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse([]string{"-log-format=xml"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestPathFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagutil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		f       flag.Value
+		input   string
+		wantErr bool
+	}{
+		{&FilePathFlag{}, file, false},
+		{&FilePathFlag{Readable: true}, file, false},
+		{&FilePathFlag{}, dir, true},
+		{&FilePathFlag{}, missing, true},
+		{&DirPathFlag{}, dir, false},
+		{&DirPathFlag{Readable: true}, dir, false},
+		{&DirPathFlag{}, file, true},
+		{&DirPathFlag{}, missing, true},
+	}
+
+	for i, tt := range tests {
+		err := tt.f.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: want err=%t got=%v", i, tt.wantErr, err)
+			continue
+		}
+		want := tt.input
+		if tt.wantErr {
+			want = ""
+		}
+		if got := tt.f.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestPathFlagsUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	dir, err := ioutil.TempDir("", "flagutil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0200); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := (&FilePathFlag{}).Set(file); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (&FilePathFlag{Readable: true}).Set(file); err == nil {
+		t.Errorf("expected error for unreadable file")
+	}
+}
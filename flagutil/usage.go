@@ -0,0 +1,26 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// PrintDefaultsWithEnv prints the usage of the flags of fs as PrintDefaults
+// does, adding to each the environment variable SetFlagsFromEnv reads it from
+// with the given prefix, and the key yamlutil.SetFlagsFromYaml reads it from.
+// For example, with prefix=PREFIX:
+//
+//	-some-flag string
+//	    what the flag does
+//	    env: PREFIX_SOME_FLAG, yaml: SOME_FLAG
+func PrintDefaultsWithEnv(fs *flag.FlagSet, prefix string) {
+	printDefaults(fs, func(f *flag.Flag) string {
+		return fmt.Sprintf("%s\nenv: %s, yaml: %s", f.Usage, envKey(prefix, f.Name), yamlKey(f.Name))
+	})
+}
+
+// yamlKey returns the key yamlutil.SetFlagsFromYaml sets the flag name from.
+func yamlKey(name string) string {
+	return strings.Replace(strings.ToUpper(name), "-", "_", -1)
+}
@@ -0,0 +1,29 @@
+package flagutil
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+)
+
+func TestPrintDefaultsWithEnv(t *testing.T) {
+	out := &bytes.Buffer{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.String("bind-address", "0.0.0.0", "address to listen on")
+	fs.Bool("debug", false, "enable debug logging")
+	if err := DeprecatedAlias(fs, "listen", "bind-address"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	PrintDefaultsWithEnv(fs, "MYAPP")
+	want := "  -bind-address string\n" +
+		"    \taddress to listen on\n" +
+		"    \tenv: MYAPP_BIND_ADDRESS, yaml: BIND_ADDRESS (default \"0.0.0.0\")\n" +
+		"  -debug\n" +
+		"    \tenable debug logging\n" +
+		"    \tenv: MYAPP_DEBUG, yaml: DEBUG\n"
+	if got := out.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
@@ -0,0 +1,104 @@
+package httputil
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal, the name of an
+// authenticated client.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal the authentication middleware
+// stored in ctx, if any.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	return p, ok
+}
+
+// CredentialFunc returns the password of user, or false if there is no such
+// user.
+type CredentialFunc func(user string) (password string, ok bool)
+
+// BasicAuthMiddleware requires requests to the next handler to carry HTTP
+// Basic credentials, storing the user name as the principal in the request
+// context. Other requests get 401 Unauthorized.
+type BasicAuthMiddleware struct {
+	next  http.Handler
+	realm string
+	creds CredentialFunc
+}
+
+// NewBasicAuthMiddleware returns a BasicAuthMiddleware checking requests to
+// next against creds, with realm named in challenges.
+func NewBasicAuthMiddleware(next http.Handler, realm string, creds CredentialFunc) *BasicAuthMiddleware {
+	return &BasicAuthMiddleware{next: next, realm: realm, creds: creds}
+}
+
+func (m *BasicAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if ok {
+		want, found := m.creds(user)
+		// Compare even for unknown users, so that the response time does
+		// not reveal which users exist.
+		ok = subtle.ConstantTimeCompare([]byte(pass), []byte(want)) == 1 && found
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", m.realm))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	m.next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), user)))
+}
+
+// TokenVerifier verifies a bearer token, returning the principal it was
+// issued to.
+type TokenVerifier func(ctx context.Context, token string) (principal string, err error)
+
+// BearerAuthMiddleware requires requests to the next handler to carry a
+// bearer token in the Authorization header, storing the principal the token
+// verifies as in the request context. Other requests get 401 Unauthorized.
+type BearerAuthMiddleware struct {
+	next   http.Handler
+	verify TokenVerifier
+}
+
+// NewBearerAuthMiddleware returns a BearerAuthMiddleware checking the tokens
+// of requests to next with verify.
+func NewBearerAuthMiddleware(next http.Handler, verify TokenVerifier) *BearerAuthMiddleware {
+	return &BearerAuthMiddleware{next: next, verify: verify}
+}
+
+func (m *BearerAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	principal, err := m.verify(r.Context(), token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	m.next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+}
+
+// bearerToken returns the token of r's Authorization header, whose scheme is
+// case-insensitive.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
@@ -0,0 +1,114 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// principalHandler writes the principal of the request as the body.
+var principalHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	p, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "no principal", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(p))
+})
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	creds := func(user string) (string, bool) {
+		if user == "admin" {
+			return "hunter2", true
+		}
+		return "", false
+	}
+	h := NewBasicAuthMiddleware(principalHandler, "metrics", creds)
+
+	for i, tt := range []struct {
+		user, pass string
+		noAuth     bool
+		wantCode   int
+		wantBody   string
+	}{
+		{user: "admin", pass: "hunter2", wantCode: http.StatusOK, wantBody: "admin"},
+		{user: "admin", pass: "hunter3", wantCode: http.StatusUnauthorized},
+		{user: "admin", pass: "", wantCode: http.StatusUnauthorized},
+		{user: "nobody", pass: "", wantCode: http.StatusUnauthorized},
+		{noAuth: true, wantCode: http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if !tt.noAuth {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("case %d: want code=%d got=%d", i, tt.wantCode, w.Code)
+			continue
+		}
+		if tt.wantCode == http.StatusOK {
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("case %d: want body=%q got=%q", i, tt.wantBody, got)
+			}
+		} else if want, got := `Basic realm="metrics"`, w.Header().Get("WWW-Authenticate"); got != want {
+			t.Errorf("case %d: want WWW-Authenticate=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	verify := func(ctx context.Context, token string) (string, error) {
+		if token == "s3cret" {
+			return "system:node", nil
+		}
+		return "", errors.New("invalid token")
+	}
+	h := NewBearerAuthMiddleware(principalHandler, verify)
+
+	for i, tt := range []struct {
+		auth          string
+		wantCode      int
+		wantBody      string
+		wantChallenge string
+	}{
+		{auth: "Bearer s3cret", wantCode: http.StatusOK, wantBody: "system:node"},
+		{auth: "bearer s3cret", wantCode: http.StatusOK, wantBody: "system:node"},
+		{auth: "Bearer wrong", wantCode: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token"`},
+		{auth: "Bearer ", wantCode: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{auth: "Basic YWRtaW46aHVudGVyMg==", wantCode: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{auth: "", wantCode: http.StatusUnauthorized, wantChallenge: "Bearer"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("case %d: want code=%d got=%d", i, tt.wantCode, w.Code)
+			continue
+		}
+		if tt.wantCode == http.StatusOK {
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("case %d: want body=%q got=%q", i, tt.wantBody, got)
+			}
+		} else if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+			t.Errorf("case %d: want WWW-Authenticate=%q got=%q", i, tt.wantChallenge, got)
+		}
+	}
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	if _, ok := PrincipalFromContext(context.Background()); ok {
+		t.Errorf("want no principal in empty context")
+	}
+	ctx := WithPrincipal(context.Background(), "admin")
+	if p, ok := PrincipalFromContext(ctx); !ok || p != "admin" {
+		t.Errorf("want principal %q, got %q (ok=%t)", "admin", p, ok)
+	}
+}
@@ -0,0 +1,157 @@
+package httputil
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// CaptureWriter wraps an http.ResponseWriter, recording the status code and
+// the number of body bytes written through it, for access logs and metrics.
+//
+// Pass Writer to the handler rather than the CaptureWriter itself, so that it
+// can still flush, hijack or push when the wrapped writer can.
+type CaptureWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+// NewCaptureWriter returns a CaptureWriter wrapping w.
+func NewCaptureWriter(w http.ResponseWriter) *CaptureWriter {
+	return &CaptureWriter{ResponseWriter: w}
+}
+
+// Writer returns an http.ResponseWriter that writes through w and implements
+// http.Flusher, http.Hijacker and http.Pusher exactly when the wrapped writer
+// does, so that handlers checking for them behave as they would unwrapped.
+func (w *CaptureWriter) Writer() http.ResponseWriter {
+	_, flush := w.ResponseWriter.(http.Flusher)
+	_, hijack := w.ResponseWriter.(http.Hijacker)
+	_, push := w.ResponseWriter.(http.Pusher)
+	f, h, p := captureFlusher{w}, captureHijacker{w}, capturePusher{w}
+	switch {
+	case flush && hijack && push:
+		return struct {
+			*CaptureWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, f, h, p}
+	case flush && hijack:
+		return struct {
+			*CaptureWriter
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case flush && push:
+		return struct {
+			*CaptureWriter
+			http.Flusher
+			http.Pusher
+		}{w, f, p}
+	case hijack && push:
+		return struct {
+			*CaptureWriter
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case flush:
+		return struct {
+			*CaptureWriter
+			http.Flusher
+		}{w, f}
+	case hijack:
+		return struct {
+			*CaptureWriter
+			http.Hijacker
+		}{w, h}
+	case push:
+		return struct {
+			*CaptureWriter
+			http.Pusher
+		}{w, p}
+	}
+	return w
+}
+
+// Status returns the status code written, or http.StatusOK if none has been,
+// as that is what is sent. A connection hijacked before writing a header
+// reports http.StatusSwitchingProtocols.
+func (w *CaptureWriter) Status() int {
+	if w.status == 0 {
+		if w.hijacked {
+			return http.StatusSwitchingProtocols
+		}
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes returns the number of body bytes written. Bytes written to a
+// hijacked connection are not counted.
+func (w *CaptureWriter) Bytes() int64 {
+	return w.bytes
+}
+
+// WroteHeader reports whether the header has been written, after which it
+// can no longer be changed.
+func (w *CaptureWriter) WroteHeader() bool {
+	return w.wroteHeader
+}
+
+// Hijacked reports whether the connection has been hijacked.
+func (w *CaptureWriter) Hijacked() bool {
+	return w.hijacked
+}
+
+func (w *CaptureWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *CaptureWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *CaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type captureFlusher struct {
+	w *CaptureWriter
+}
+
+func (f captureFlusher) Flush() {
+	f.w.wroteHeader = true
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type captureHijacker struct {
+	w *CaptureWriter
+}
+
+func (h captureHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+type capturePusher struct {
+	w *CaptureWriter
+}
+
+func (p capturePusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
@@ -0,0 +1,121 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCaptureWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewCaptureWriter(rec)
+	if w.WroteHeader() || w.Status() != http.StatusOK || w.Bytes() != 0 {
+		t.Errorf("unexpected initial state: wroteHeader=%t status=%d bytes=%d", w.WroteHeader(), w.Status(), w.Bytes())
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+
+	if !w.WroteHeader() {
+		t.Errorf("want header written")
+	}
+	if want, got := http.StatusCreated, w.Status(); got != want {
+		t.Errorf("status: want=%d got=%d", want, got)
+	}
+	if want, got := int64(11), w.Bytes(); got != want {
+		t.Errorf("bytes: want=%d got=%d", want, got)
+	}
+	if want, got := "hello world", rec.Body.String(); got != want {
+		t.Errorf("body: want=%q got=%q", want, got)
+	}
+}
+
+func TestCaptureWriterImplicitHeader(t *testing.T) {
+	w := NewCaptureWriter(httptest.NewRecorder())
+	w.Write([]byte("ok"))
+	if !w.WroteHeader() || w.Status() != http.StatusOK {
+		t.Errorf("want implicit 200, got wroteHeader=%t status=%d", w.WroteHeader(), w.Status())
+	}
+}
+
+// plainWriter implements only http.ResponseWriter.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestCaptureWriterInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewCaptureWriter(rec)
+	f, ok := w.Writer().(http.Flusher)
+	if !ok {
+		t.Fatalf("want Flusher for a writer that implements it")
+	}
+	f.Flush()
+	if !rec.Flushed || !w.WroteHeader() {
+		t.Errorf("want Flush passed through")
+	}
+	if _, ok := w.Writer().(http.Hijacker); ok {
+		t.Errorf("want no Hijacker for a writer without it")
+	}
+	if _, ok := w.Writer().(http.Pusher); ok {
+		t.Errorf("want no Pusher for a writer without it")
+	}
+	if w.Unwrap() != rec {
+		t.Errorf("want Unwrap to return the wrapped writer")
+	}
+
+	w = NewCaptureWriter(plainWriter{httptest.NewRecorder()})
+	switch w.Writer().(type) {
+	case http.Flusher, http.Hijacker, http.Pusher:
+		t.Errorf("want only http.ResponseWriter for a plain writer")
+	}
+	w.Writer().Write([]byte("ok"))
+	if w.Bytes() != 2 {
+		t.Errorf("want writes captured, got %d bytes", w.Bytes())
+	}
+}
+
+func TestCaptureWriterHijack(t *testing.T) {
+	var (
+		hijackErr error
+		status    int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := NewCaptureWriter(rw)
+		h, ok := w.Writer().(http.Hijacker)
+		if !ok {
+			hijackErr = errors.New("no Hijacker")
+			return
+		}
+		conn, _, err := h.Hijack()
+		if err != nil {
+			hijackErr = err
+			return
+		}
+		if !w.Hijacked() {
+			hijackErr = errors.New("Hijacked is false")
+		}
+		status = w.Status()
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+		conn.Close()
+	}))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if hijackErr != nil {
+		t.Fatalf("unexpected Hijack error: %v", hijackErr)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("want=%d got=%d", http.StatusNoContent, resp.StatusCode)
+	}
+	if status != http.StatusSwitchingProtocols {
+		t.Errorf("status: want=%d got=%d", http.StatusSwitchingProtocols, status)
+	}
+}
@@ -0,0 +1,32 @@
+package httputil
+
+import (
+	"net/http"
+	"time"
+)
+
+// Logger is the logging interface used by the middleware of this package. A
+// *capnslog.PackageLogger satisfies it.
+type Logger interface {
+	Infof(format string, args ...interface{})
+}
+
+// LoggingMiddleware logs the method, path, status, response size and
+// duration of every request it passes to the next handler.
+type LoggingMiddleware struct {
+	next   http.Handler
+	logger Logger
+}
+
+// NewLoggingMiddleware returns a LoggingMiddleware logging requests to next
+// with logger.
+func NewLoggingMiddleware(next http.Handler, logger Logger) *LoggingMiddleware {
+	return &LoggingMiddleware{next: next, logger: logger}
+}
+
+func (l *LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	cw := NewCaptureWriter(w)
+	l.next.ServeHTTP(cw.Writer(), r)
+	l.logger.Infof("HTTP %s %s %d %dB %v", r.Method, r.URL.Path, cw.Status(), cw.Bytes(), time.Since(start))
+}
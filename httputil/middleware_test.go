@@ -0,0 +1,92 @@
+package httputil
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	for i, tt := range []struct {
+		handler http.HandlerFunc
+		url     string
+		want    string
+	}{
+		{
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			"/healthz?verbose=1",
+			`^HTTP GET /healthz 200 2B \S+$`,
+		},
+		{
+			func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			"/missing",
+			`^HTTP GET /missing 404 19B \S+$`,
+		},
+		{
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			"/jobs",
+			`^HTTP GET /jobs 202 0B \S+$`,
+		},
+	} {
+		logger := &testLogger{}
+		h := NewLoggingMiddleware(tt.handler, logger)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.url, nil))
+
+		if len(logger.lines) != 1 {
+			t.Errorf("case %d: want 1 line, got %q", i, logger.lines)
+			continue
+		}
+		if !regexp.MustCompile(tt.want).MatchString(logger.lines[0]) {
+			t.Errorf("case %d: want match for %s, got %q", i, tt.want, logger.lines[0])
+		}
+	}
+}
+
+// hijackRecorder is a ResponseRecorder whose connection can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, _ := net.Pipe()
+	return c, nil, nil
+}
+
+func TestLoggingMiddlewareHijack(t *testing.T) {
+	logger := &testLogger{}
+	h := NewLoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatalf("want Hijacker passed through")
+		}
+		conn, _, _ := hj.Hijack()
+		conn.Close()
+	}), logger)
+	h.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest("GET", "/ws", nil))
+
+	if len(logger.lines) != 1 {
+		t.Fatalf("want 1 line, got %q", logger.lines)
+	}
+	if want := `^HTTP GET /ws 101 0B \S+$`; !regexp.MustCompile(want).MatchString(logger.lines[0]) {
+		t.Errorf("want match for %s, got %q", want, logger.lines[0])
+	}
+}
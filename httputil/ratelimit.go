@@ -0,0 +1,149 @@
+package httputil
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore holds the token bucket of each client of a
+// RateLimitMiddleware.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key. If the bucket is empty, it
+	// returns false and how long until a token is available.
+	Take(key string) (ok bool, retryAfter time.Duration)
+}
+
+// KeyFunc returns the key a request is rate limited by.
+type KeyFunc func(*http.Request) string
+
+// ClientIP returns the IP address of the client that sent r, the default
+// KeyFunc. Proxy headers such as X-Forwarded-For are not trusted; behind a
+// proxy, use a KeyFunc that reads them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitMiddleware limits the rate at which each client may send requests
+// to the next handler, responding to requests over the limit with 429 Too
+// Many Requests and a Retry-After header.
+type RateLimitMiddleware struct {
+	next  http.Handler
+	store RateLimitStore
+	key   KeyFunc
+}
+
+// NewRateLimitMiddleware returns a RateLimitMiddleware limiting requests to
+// next with the buckets in store, one for each key returned by key. A nil key
+// limits each client IP.
+func NewRateLimitMiddleware(next http.Handler, store RateLimitStore, key KeyFunc) *RateLimitMiddleware {
+	if key == nil {
+		key = ClientIP
+	}
+	return &RateLimitMiddleware{next: next, store: store, key: key}
+}
+
+func (m *RateLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ok, retryAfter := m.store.Take(m.key(r))
+	if !ok {
+		secs := int64(math.Ceil(retryAfter.Seconds()))
+		if secs < 1 {
+			secs = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	m.next.ServeHTTP(w, r)
+}
+
+// MemoryRateLimitStore is a RateLimitStore that keeps the buckets in memory,
+// evicting those of clients that have been idle for a while.
+type MemoryRateLimitStore struct {
+	rate  float64
+	burst float64
+	idle  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore returns a MemoryRateLimitStore allowing each key
+// rate requests per second, in bursts of up to burst requests. Buckets unused
+// for idle are evicted; an idle of zero defaults to the time an empty bucket
+// takes to refill, after which evicting it makes no difference. Like
+// time.NewTicker, it panics if rate is not positive or burst is less than one,
+// which would make every request wait forever.
+func NewMemoryRateLimitStore(rate float64, burst int, idle time.Duration) *MemoryRateLimitStore {
+	if !(rate > 0) {
+		panic(fmt.Sprintf("httputil: non-positive rate %v for NewMemoryRateLimitStore", rate))
+	}
+	if burst < 1 {
+		panic(fmt.Sprintf("httputil: burst %d for NewMemoryRateLimitStore is less than 1", burst))
+	}
+	if idle == 0 {
+		idle = time.Duration(float64(burst) / rate * float64(time.Second))
+	}
+	return &MemoryRateLimitStore{
+		rate:    rate,
+		burst:   float64(burst),
+		idle:    idle,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(key string) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: s.burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * s.rate
+	if b.tokens > s.burst {
+		b.tokens = s.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / s.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep evicts idle buckets, at most once per idle period. The caller must
+// hold s.mu.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.idle {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= s.idle {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,157 @@
+package httputil
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewMemoryRateLimitStore(2, 3, 0)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := s.Take("a"); !ok {
+			t.Fatalf("request %d: want allowed within burst", i)
+		}
+	}
+	ok, retryAfter := s.Take("a")
+	if ok {
+		t.Fatalf("want request over burst limited")
+	}
+	if want := 500 * time.Millisecond; retryAfter != want {
+		t.Errorf("retryAfter: want=%v got=%v", want, retryAfter)
+	}
+	if ok, _ := s.Take("b"); !ok {
+		t.Errorf("want other key allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := s.Take("a"); !ok {
+		t.Errorf("want allowed after refill")
+	}
+	if ok, _ := s.Take("a"); ok {
+		t.Errorf("want limited again")
+	}
+}
+
+func TestMemoryRateLimitStoreEviction(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewMemoryRateLimitStore(1, 2, 0)
+	s.now = func() time.Time { return now }
+
+	s.Take("a")
+	now = now.Add(time.Second)
+	s.Take("b")
+	if len(s.buckets) != 2 {
+		t.Fatalf("want 2 buckets, got %d", len(s.buckets))
+	}
+
+	now = now.Add(time.Second)
+	s.Take("b")
+	if _, ok := s.buckets["a"]; ok {
+		t.Errorf("want idle bucket evicted")
+	}
+	if _, ok := s.buckets["b"]; !ok {
+		t.Errorf("want active bucket kept")
+	}
+}
+
+func TestMemoryRateLimitStoreInvalid(t *testing.T) {
+	for i, tt := range []struct {
+		rate  float64
+		burst int
+	}{
+		{0, 1},
+		{-1, 1},
+		{math.NaN(), 1},
+		{1, 0},
+		{1, -1},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("case %d: want panic for rate=%v burst=%d", i, tt.rate, tt.burst)
+				}
+			}()
+			NewMemoryRateLimitStore(tt.rate, tt.burst, 0)
+		}()
+	}
+}
+
+type fakeStore struct {
+	keys       []string
+	ok         bool
+	retryAfter time.Duration
+}
+
+func (s *fakeStore) Take(key string) (bool, time.Duration) {
+	s.keys = append(s.keys, key)
+	return s.ok, s.retryAfter
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for i, tt := range []struct {
+		store          *fakeStore
+		key            KeyFunc
+		wantKey        string
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{
+			store:    &fakeStore{ok: true},
+			wantKey:  "192.0.2.1",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			store:          &fakeStore{retryAfter: 1500 * time.Millisecond},
+			wantKey:        "192.0.2.1",
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "2",
+		},
+		{
+			store:          &fakeStore{retryAfter: time.Millisecond},
+			key:            func(r *http.Request) string { return r.Header.Get("X-API-Key") },
+			wantKey:        "secret",
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "1",
+		},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		NewRateLimitMiddleware(ok, tt.store, tt.key).ServeHTTP(w, r)
+
+		if len(tt.store.keys) != 1 || tt.store.keys[0] != tt.wantKey {
+			t.Errorf("case %d: want key %q, got %q", i, tt.wantKey, tt.store.keys)
+		}
+		if w.Code != tt.wantCode {
+			t.Errorf("case %d: want code=%d got=%d", i, tt.wantCode, w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("case %d: want Retry-After=%q got=%q", i, tt.wantRetryAfter, got)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for i, tt := range []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "2001:db8::1"},
+		{"192.0.2.1", "192.0.2.1"},
+	} {
+		r := &http.Request{RemoteAddr: tt.remoteAddr}
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeoutMiddleware gives requests to the next handler a deadline, responding
+// with Status if the handler has not finished by then. The handler's context
+// is canceled at the deadline, and its writes after it fail with
+// http.ErrHandlerTimeout.
+//
+// The response is buffered until the handler returns, so requests that stream
+// their response, or upgrade the connection as websockets do, must be exempt.
+// Upgrade requests are always exempt.
+type TimeoutMiddleware struct {
+	next    http.Handler
+	timeout time.Duration
+	exempt  []string
+
+	// Status is written on timeout. Defaults to 503 Service Unavailable;
+	// 504 Gateway Timeout suits proxies.
+	Status int
+}
+
+// NewTimeoutMiddleware returns a TimeoutMiddleware giving requests to next a
+// deadline of timeout, except those whose path starts with one of exempt.
+func NewTimeoutMiddleware(next http.Handler, timeout time.Duration, exempt ...string) *TimeoutMiddleware {
+	return &TimeoutMiddleware{
+		next:    next,
+		timeout: timeout,
+		exempt:  exempt,
+		Status:  http.StatusServiceUnavailable,
+	}
+}
+
+func (m *TimeoutMiddleware) isExempt(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" {
+		return true
+	}
+	for _, prefix := range m.exempt {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *TimeoutMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.isExempt(r) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), m.timeout)
+	defer cancel()
+	tw := &timeoutWriter{ctx: ctx, header: http.Header{}}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		m.next.ServeHTTP(tw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	timedOut := false
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		default:
+			timedOut = true
+		}
+	}
+
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	// A handler that returned keeps its response even if the deadline has
+	// passed since, unless one of its writes was rejected.
+	if timedOut || tw.rejected {
+		http.Error(w, http.StatusText(m.Status), m.Status)
+		return
+	}
+	dst := w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	w.WriteHeader(tw.status)
+	w.Write(tw.body.Bytes())
+}
+
+// timeoutWriter buffers the response of a TimeoutMiddleware's handler, until
+// ctx expires.
+type timeoutWriter struct {
+	ctx    context.Context
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+	status int
+	// rejected is set once a write fails for the deadline having passed.
+	rejected bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		w.rejected = true
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		w.rejected = true
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+}
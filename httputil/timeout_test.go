@@ -0,0 +1,111 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handlerErr := make(chan error, 1)
+	// slow returns only after its deadline, writing once it has passed.
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("late"))
+		handlerErr <- err
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	// unbounded reports whether the request has a deadline.
+	unbounded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			w.Write([]byte("deadline"))
+			return
+		}
+		w.Write([]byte("no deadline"))
+	})
+
+	for i, tt := range []struct {
+		handler    http.Handler
+		timeout    time.Duration
+		status     int
+		path       string
+		upgrade    bool
+		timedOut   bool
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{handler: fast, timeout: time.Minute, path: "/", wantCode: http.StatusCreated, wantBody: "done", wantHeader: "1"},
+		{handler: unbounded, timeout: time.Minute, path: "/", wantCode: http.StatusOK, wantBody: "deadline"},
+		{handler: slow, timeout: time.Millisecond, path: "/", timedOut: true, wantCode: http.StatusServiceUnavailable, wantBody: "Service Unavailable\n"},
+		{handler: slow, timeout: time.Millisecond, status: http.StatusGatewayTimeout, path: "/", timedOut: true, wantCode: http.StatusGatewayTimeout, wantBody: "Gateway Timeout\n"},
+		{handler: unbounded, timeout: time.Millisecond, path: "/watch/pods", wantCode: http.StatusOK, wantBody: "no deadline"},
+		{handler: unbounded, timeout: time.Millisecond, path: "/ws", upgrade: true, wantCode: http.StatusOK, wantBody: "no deadline"},
+	} {
+		m := NewTimeoutMiddleware(tt.handler, tt.timeout, "/watch/")
+		if tt.status != 0 {
+			m.Status = tt.status
+		}
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.upgrade {
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("case %d: want code=%d got=%d", i, tt.wantCode, w.Code)
+		}
+		if got := w.Body.String(); got != tt.wantBody {
+			t.Errorf("case %d: want body=%q got=%q", i, tt.wantBody, got)
+		}
+		if got := w.Header().Get("X-Test"); got != tt.wantHeader {
+			t.Errorf("case %d: want X-Test=%q got=%q", i, tt.wantHeader, got)
+		}
+		if tt.timedOut {
+			if err := <-handlerErr; err != http.ErrHandlerTimeout {
+				t.Errorf("case %d: want handler write error %v, got %v", i, http.ErrHandlerTimeout, err)
+			}
+		}
+	}
+}
+
+func TestTimeoutMiddlewareFinishedInTime(t *testing.T) {
+	// The handler returns in time, but holds the response lock until the
+	// deadline has passed, so the middleware only looks at the response
+	// after it.
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+		tw := w.(*timeoutWriter)
+		tw.mu.Lock()
+		ctx := r.Context()
+		go func() {
+			<-ctx.Done()
+			tw.mu.Unlock()
+		}()
+	})
+	w := httptest.NewRecorder()
+	NewTimeoutMiddleware(h, 50*time.Millisecond).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("want 200 %q, got %d %q", "done", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutMiddlewarePanic(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("want panic %q, got %v", "boom", p)
+		}
+	}()
+	NewTimeoutMiddleware(h, time.Minute).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
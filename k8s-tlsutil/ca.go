@@ -0,0 +1,112 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// CA is a certificate authority held in memory. It is safe for concurrent
+// use. Serial numbers are assigned sequentially, starting after a random
+// value unless restored with SetLastSerial, so that an embedded CA never
+// reuses one.
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+
+	// KeyOptions selects the keys Issue generates.
+	KeyOptions []KeyOption
+	// Duration is the validity of issued certificates. Defaults to one
+	// year.
+	Duration time.Duration
+	// OnIssue, if set, is called with every certificate before it is
+	// returned, for example to record it or to persist the last serial. If
+	// it fails, the certificate is not returned, but its serial is not
+	// reused.
+	OnIssue func(*x509.Certificate) error
+
+	mu     sync.Mutex
+	serial *big.Int
+}
+
+// NewCA returns a CA issuing with cert and key, which must belong together.
+func NewCA(cert *x509.Certificate, key crypto.Signer) (*CA, error) {
+	if !cert.IsCA {
+		return nil, errors.New("certificate is not a CA")
+	}
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("private key does not match CA certificate")
+	}
+	start, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key, serial: start}, nil
+}
+
+// LastSerial returns the serial number of the last certificate issued.
+func (ca *CA) LastSerial() *big.Int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return new(big.Int).Set(ca.serial)
+}
+
+// SetLastSerial sets the serial number after which issuing continues, as
+// recorded by OnIssue in a previous run.
+func (ca *CA) SetLastSerial(serial *big.Int) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.serial = new(big.Int).Set(serial)
+}
+
+// Issue generates a key and issues a certificate for it as described by cfg.
+func (ca *CA) Issue(cfg CertConfig, opts ...CertOption) (*x509.Certificate, crypto.Signer, error) {
+	key, err := NewKey(ca.KeyOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := ca.issue(func(o CertOption) (*x509.Certificate, error) {
+		return NewSignedCertificate(cfg, key, ca.Cert, ca.Key, ca.Duration, append(append([]CertOption(nil), opts...), o)...)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// IssueCSR issues a certificate for a PEM-encoded certificate signing
+// request. As with SignCSR, the requested names are used as is.
+func (ca *CA) IssueCSR(csrPEM []byte, profile SigningProfile, opts ...CertOption) (*x509.Certificate, error) {
+	if profile.Duration == 0 {
+		profile.Duration = ca.Duration
+	}
+	return ca.issue(func(o CertOption) (*x509.Certificate, error) {
+		return SignCSR(csrPEM, ca.Cert, ca.Key, profile, append(append([]CertOption(nil), opts...), o)...)
+	})
+}
+
+// issue calls sign with an option assigning the next serial number, and
+// hands the result to OnIssue.
+func (ca *CA) issue(sign func(CertOption) (*x509.Certificate, error)) (*x509.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	serial := new(big.Int).Add(ca.serial, big.NewInt(1))
+	cert, err := sign(func(c *x509.Certificate) {
+		c.SerialNumber = serial
+	})
+	if err != nil {
+		return nil, err
+	}
+	ca.serial = serial
+	if ca.OnIssue != nil {
+		if err := ca.OnIssue(cert); err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
@@ -0,0 +1,155 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// CertOption adjusts the template of a certificate before it is signed. The
+// certificate constructors apply options after filling in the template from
+// their arguments, so an option may override any field. Options beyond the
+// ones below can be written as plain functions.
+type CertOption func(*x509.Certificate)
+
+// WithExtensions adds extensions to the certificate verbatim.
+func WithExtensions(exts ...pkix.Extension) CertOption {
+	return func(c *x509.Certificate) {
+		c.ExtraExtensions = append(c.ExtraExtensions, exts...)
+	}
+}
+
+// WithOCSPServers sets the OCSP responder URLs of the authority information
+// access extension.
+func WithOCSPServers(urls ...string) CertOption {
+	return func(c *x509.Certificate) {
+		c.OCSPServer = urls
+	}
+}
+
+// WithIssuingCertificateURLs sets the CA issuers URLs of the authority
+// information access extension.
+func WithIssuingCertificateURLs(urls ...string) CertOption {
+	return func(c *x509.Certificate) {
+		c.IssuingCertificateURL = urls
+	}
+}
+
+// WithCRLDistributionPoints sets the CRL distribution point URLs.
+func WithCRLDistributionPoints(urls ...string) CertOption {
+	return func(c *x509.Certificate) {
+		c.CRLDistributionPoints = urls
+	}
+}
+
+// WithPolicyOIDs sets the certificate policy identifiers.
+func WithPolicyOIDs(oids ...asn1.ObjectIdentifier) CertOption {
+	return func(c *x509.Certificate) {
+		c.PolicyIdentifiers = oids
+	}
+}
+
+// WithRandomSerial gives the certificate a random serial number. Leaf and
+// intermediate certificates get one by default; self-signed CAs get serial 0
+// unless this option is used.
+func WithRandomSerial() CertOption {
+	return func(c *x509.Certificate) {
+		c.SerialNumber = nil
+	}
+}
+
+// WithMaxPathLen limits the number of intermediate CAs that may follow a CA
+// certificate in a chain. 0 allows it to sign only leaf certificates, and a
+// negative value removes the limit.
+func WithMaxPathLen(n int) CertOption {
+	return func(c *x509.Certificate) {
+		if n < 0 {
+			n = -1
+		}
+		c.MaxPathLen = n
+		c.MaxPathLenZero = n == 0
+	}
+}
+
+// WithPermittedDNSDomains adds a name constraints extension restricting the
+// DNS names a CA may issue certificates for to the given domains and their
+// subdomains.
+func WithPermittedDNSDomains(domains ...string) CertOption {
+	return func(c *x509.Certificate) {
+		c.PermittedDNSDomains = domains
+		c.PermittedDNSDomainsCritical = true
+	}
+}
+
+// WithExcludedDNSDomains adds a name constraints extension forbidding a CA
+// from issuing certificates for the given domains and their subdomains.
+func WithExcludedDNSDomains(domains ...string) CertOption {
+	return func(c *x509.Certificate) {
+		c.ExcludedDNSDomains = domains
+		c.PermittedDNSDomainsCritical = true
+	}
+}
+
+// WithSignatureAlgorithm selects the algorithm the issuer signs the
+// certificate with, instead of the x509 package's default for the issuer's
+// key type. SignatureAlgorithmFor picks the algorithm for a key type and hash.
+func WithSignatureAlgorithm(alg x509.SignatureAlgorithm) CertOption {
+	return func(c *x509.Certificate) {
+		c.SignatureAlgorithm = alg
+	}
+}
+
+// SignatureAlgorithmFor returns the signature algorithm an issuer with the
+// given key uses with hash h: SHA-256, SHA-384 or SHA-512 with RSA PKCS#1
+// v1.5 or ECDSA. Ed25519 has a single algorithm, returned for any h.
+func SignatureAlgorithmFor(issuerKey crypto.PublicKey, h crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch issuerKey.(type) {
+	case *rsa.PublicKey:
+		switch h {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case *ecdsa.PublicKey:
+		switch h {
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported key type %T", issuerKey)
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported hash %v", h)
+}
+
+// applyCertOptions applies opts to tmpl and fills in a random serial number
+// if an option cleared it.
+func applyCertOptions(tmpl *x509.Certificate, opts []CertOption) error {
+	for _, opt := range opts {
+		opt(tmpl)
+	}
+	if tmpl.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+		if err != nil {
+			return err
+		}
+		tmpl.SerialNumber = serial
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package k8stlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math"
+	"math/big"
+	"time"
+)
+
+// NewSignedCACertificate creates an intermediate CA certificate for key,
+// signed by caCert. maxPathLen limits the number of further intermediates
+// below it: 0 allows it to sign only leaf certificates, and a negative value
+// leaves the path length unconstrained. The validity defaults to five years.
+func NewSignedCACertificate(cfg CertConfig, key crypto.Signer, caCert *x509.Certificate, caKey crypto.Signer, maxPathLen int, validDuration time.Duration, opts ...CertOption) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+
+	dur := Duration365d * 5
+	if validDuration != 0 {
+		dur = validDuration
+	}
+	notBefore, notAfter := cfg.validity(dur)
+
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   cfg.CommonName,
+			Organization: cfg.Organization,
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage(key.Public()) | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}
+
+	if err := setKeyIDs(&tmpl, key.Public(), caCert); err != nil {
+		return nil, err
+	}
+	if err := applyCertOptions(&tmpl, opts); err != nil {
+		return nil, err
+	}
+
+	certDERBytes, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certDERBytes)
+}
+
+// BuildChain orders leaf and the issuers of its chain found among certs,
+// leaf first. The chain ends at a self-signed root or at the last issuer
+// present in certs. Certificates in certs that are not part of the chain are
+// ignored.
+func BuildChain(leaf *x509.Certificate, certs ...*x509.Certificate) ([]*x509.Certificate, error) {
+	if leaf == nil {
+		return nil, errors.New("no leaf certificate")
+	}
+	chain := []*x509.Certificate{leaf}
+	for cur := leaf; !isSelfSigned(cur); {
+		var issuer *x509.Certificate
+		for _, c := range certs {
+			if c.IsCA && bytes.Equal(cur.RawIssuer, c.RawSubject) && cur.CheckSignatureFrom(c) == nil {
+				issuer = c
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		if len(chain) > len(certs) {
+			return nil, errors.New("certificate chain contains a loop")
+		}
+		chain = append(chain, issuer)
+		cur = issuer
+	}
+	return chain, nil
+}
+
+func isSelfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil
+}
+
+// EncodeCertificateChainPEM PEM-encodes certs in order, as expected for a
+// TLS server's certificate file: leaf first, then each issuer.
+func EncodeCertificateChainPEM(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, c := range certs {
+		buf.Write(EncodeCertificatePEM(c))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,111 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math"
+	"math/big"
+	"time"
+)
+
+// SigningProfile controls the certificates SignCSR issues.
+type SigningProfile struct {
+	// ExtKeyUsage defaults to both server and client authentication.
+	ExtKeyUsage []x509.ExtKeyUsage
+	// Duration defaults to one year.
+	Duration time.Duration
+}
+
+// NewCertificateRequest creates a PEM-encoded certificate signing request for
+// key, with the subject and alternative names from cfg.
+func NewCertificateRequest(cfg CertConfig, key crypto.Signer) ([]byte, error) {
+	tmpl := x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   cfg.CommonName,
+			Organization: cfg.Organization,
+		},
+		DNSNames:       cfg.AltNames.DNSNames,
+		IPAddresses:    cfg.AltNames.IPs,
+		EmailAddresses: cfg.AltNames.EmailAddresses,
+		URIs:           cfg.AltNames.URIs,
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &tmpl, key)
+	if err != nil {
+		return nil, err
+	}
+	block := pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: der,
+	}
+	return pem.EncodeToMemory(&block), nil
+}
+
+// ParsePEMEncodedCSR parses a PEM-encoded certificate signing request and
+// checks its signature.
+func ParsePEMEncodedCSR(pemdata []byte) (*x509.CertificateRequest, error) {
+	decoded, _ := pem.Decode(pemdata)
+	if decoded == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	csr, err := x509.ParseCertificateRequest(decoded.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
+
+// SignCSR issues a certificate for a PEM-encoded certificate signing request.
+// The subject and alternative names are taken from the request as is, so the
+// caller is responsible for deciding whether the requester may use them.
+func SignCSR(csrPEM []byte, caCert *x509.Certificate, caKey crypto.Signer, profile SigningProfile, opts ...CertOption) (*x509.Certificate, error) {
+	csr, err := ParsePEMEncodedCSR(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+
+	dur := Duration365d
+	if profile.Duration != 0 {
+		dur = profile.Duration
+	}
+	notBefore, notAfter := CertConfig{}.validity(dur)
+	usages := profile.ExtKeyUsage
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	certTmpl := x509.Certificate{
+		Subject:        csr.Subject,
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		EmailAddresses: csr.EmailAddresses,
+		URIs:           csr.URIs,
+		SerialNumber:   serial,
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+		KeyUsage:       keyUsage(csr.PublicKey),
+		ExtKeyUsage:    usages,
+	}
+	if err := setKeyIDs(&certTmpl, csr.PublicKey, caCert); err != nil {
+		return nil, err
+	}
+	if err := applyCertOptions(&certTmpl, opts); err != nil {
+		return nil, err
+	}
+	certDERBytes, err := x509.CreateCertificate(rand.Reader, &certTmpl, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certDERBytes)
+}
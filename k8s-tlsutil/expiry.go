@@ -0,0 +1,61 @@
+package k8stlsutil
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+)
+
+// IsExpired reports whether cert is past its NotAfter time.
+func IsExpired(cert *x509.Certificate) bool {
+	return time.Now().After(cert.NotAfter)
+}
+
+// ExpiresWithin reports whether cert expires within d from now, or already
+// has.
+func ExpiresWithin(cert *x509.Certificate, d time.Duration) bool {
+	return time.Now().Add(d).After(cert.NotAfter)
+}
+
+// ParsePEMEncodedCerts parses every CERTIFICATE block in pemdata, ignoring
+// other block types.
+func ParsePEMEncodedCerts(pemdata []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemdata = pem.Decode(pemdata)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// SoonestExpiry returns the certificate of a PEM bundle, such as a chain or a
+// CA bundle, that expires first. Renewal tooling can compare its NotAfter
+// against the renewal threshold.
+func SoonestExpiry(pemdata []byte) (*x509.Certificate, error) {
+	certs, err := ParsePEMEncodedCerts(pemdata)
+	if err != nil {
+		return nil, err
+	}
+	soonest := certs[0]
+	for _, c := range certs[1:] {
+		if c.NotAfter.Before(soonest.NotAfter) {
+			soonest = c
+		}
+	}
+	return soonest, nil
+}
@@ -0,0 +1,83 @@
+package k8stlsutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteKey writes PEM-encoded private key material to path atomically,
+// readable only by its owner (mode 0600).
+func WriteKey(path string, pemdata []byte) error {
+	return writeFileAtomic(path, pemdata, 0600)
+}
+
+// WriteCert writes PEM-encoded certificates to path atomically, readable by
+// everyone (mode 0644).
+func WriteCert(path string, pemdata []byte) error {
+	return writeFileAtomic(path, pemdata, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path,
+// syncs it and renames it over path, so readers see either the old or the new
+// contents, never a partial file. The mode is set explicitly rather than left
+// to the umask, and applies even when path already exists.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := stageFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	return commitFile(tmp, path)
+}
+
+// stageFile writes data to a synced temporary file in the directory of path
+// and returns its name, for commitFile to rename over path.
+func stageFile(path string, data []byte, perm os.FileMode) (tmp string, err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(perm); err != nil {
+		return "", err
+	}
+	if _, err = f.Write(data); err != nil {
+		return "", err
+	}
+	if err = f.Sync(); err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// commitFile renames the temporary file tmp over path, removing tmp if that
+// fails.
+func commitFile(tmp, path string) error {
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Sync the directory so that the rename survives a crash.
+	dir := filepath.Dir(path)
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+	d.Sync()
+	return nil
+}
@@ -0,0 +1,70 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// FingerprintSHA256 returns the SHA-256 digest of cert's DER encoding.
+func FingerprintSHA256(cert *x509.Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.Raw)
+}
+
+// FormatFingerprint renders a fingerprint as colon-separated uppercase hex
+// bytes, as printed by "openssl x509 -fingerprint".
+func FormatFingerprint(fp []byte) string {
+	parts := make([]string, len(fp))
+	for i, b := range fp {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// SubjectKeyID computes the key identifier of pub as described in RFC 5280,
+// section 4.2.1.2, method 1: the SHA-1 digest of the subject public key bits.
+func SubjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, err
+	}
+	id := sha1.Sum(spki.PublicKey.Bytes)
+	return id[:], nil
+}
+
+// setKeyIDs fills in the subject key identifier of tmpl for pub and, when
+// issuer is given, its authority key identifier. The issuer's own subject key
+// identifier is used if it has one, so that certificates chain to CAs created
+// elsewhere.
+func setKeyIDs(tmpl *x509.Certificate, pub crypto.PublicKey, issuer *x509.Certificate) error {
+	ski, err := SubjectKeyID(pub)
+	if err != nil {
+		return err
+	}
+	tmpl.SubjectKeyId = ski
+	if issuer == nil {
+		return nil
+	}
+	if len(issuer.SubjectKeyId) > 0 {
+		tmpl.AuthorityKeyId = issuer.SubjectKeyId
+		return nil
+	}
+	aki, err := SubjectKeyID(issuer.PublicKey)
+	if err != nil {
+		return err
+	}
+	tmpl.AuthorityKeyId = aki
+	return nil
+}
@@ -0,0 +1,261 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is the JSON Web Key (RFC 7517) representation of an RSA, ECDSA
+// or Ed25519 public or private key. It can be marshaled with encoding/json,
+// for example to publish an OIDC provider's signing keys.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+
+	// RSA
+	N  string `json:"n,omitempty"`
+	E  string `json:"e,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+
+	// ECDSA and Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// Private exponent or key, for all key types.
+	D string `json:"d,omitempty"`
+}
+
+// JSONWebKeySet is a JWK Set, as served from an OIDC provider's jwks_uri.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+func b64Int(i *big.Int) string {
+	return b64.EncodeToString(i.Bytes())
+}
+
+// b64Coord encodes an elliptic curve coordinate padded to the curve size, as
+// RFC 7518 requires.
+func b64Coord(i *big.Int, curve elliptic.Curve) string {
+	b := make([]byte, (curve.Params().BitSize+7)/8)
+	return b64.EncodeToString(i.FillBytes(b))
+}
+
+// NewJSONWebKey converts an RSA, ECDSA or Ed25519 public or private key to
+// a JSONWebKey. Private keys include their public part. Use and Kid are left
+// for the caller to fill in.
+func NewJSONWebKey(key interface{}) (*JSONWebKey, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			Kty: "RSA",
+			N:   b64Int(k.N),
+			E:   b64Int(big.NewInt(int64(k.E))),
+		}, nil
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		k.Precompute()
+		jwk, _ := NewJSONWebKey(&k.PublicKey)
+		jwk.D = b64Int(k.D)
+		jwk.P = b64Int(k.Primes[0])
+		jwk.Q = b64Int(k.Primes[1])
+		jwk.DP = b64Int(k.Precomputed.Dp)
+		jwk.DQ = b64Int(k.Precomputed.Dq)
+		jwk.QI = b64Int(k.Precomputed.Qinv)
+		return jwk, nil
+	case *ecdsa.PublicKey:
+		crv, err := jwkCurveName(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONWebKey{
+			Kty: "EC",
+			Crv: crv,
+			X:   b64Coord(k.X, k.Curve),
+			Y:   b64Coord(k.Y, k.Curve),
+		}, nil
+	case *ecdsa.PrivateKey:
+		jwk, err := NewJSONWebKey(&k.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwk.D = b64Coord(k.D, k.Curve)
+		return jwk, nil
+	case ed25519.PublicKey:
+		return &JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   b64.EncodeToString(k),
+		}, nil
+	case ed25519.PrivateKey:
+		jwk, _ := NewJSONWebKey(k.Public())
+		jwk.D = b64.EncodeToString(k.Seed())
+		return jwk, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+func jwkCurveName(c elliptic.Curve) (string, error) {
+	switch c {
+	case elliptic.P256():
+		return "P-256", nil
+	case elliptic.P384():
+		return "P-384", nil
+	case elliptic.P521():
+		return "P-521", nil
+	}
+	return "", fmt.Errorf("unsupported curve %s", c.Params().Name)
+}
+
+func jwkCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %q", name)
+}
+
+func decodeInt(field, s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing JWK field %q", field)
+	}
+	b, err := b64.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("JWK field %q: %v", field, err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// IsPrivate reports whether the key holds private key material.
+func (k *JSONWebKey) IsPrivate() bool {
+	return k.D != ""
+}
+
+// PublicKey returns the *rsa.PublicKey, *ecdsa.PublicKey or
+// ed25519.PublicKey held by k.
+func (k *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA public exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, err := jwkCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeInt("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("JWK field %q: %v", "x", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// PrivateKey returns the *rsa.PrivateKey, *ecdsa.PrivateKey or
+// ed25519.PrivateKey held by k.
+func (k *JSONWebKey) PrivateKey() (crypto.Signer, error) {
+	if !k.IsPrivate() {
+		return nil, errors.New("JWK holds no private key")
+	}
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		d, err := decodeInt("d", k.D)
+		if err != nil {
+			return nil, err
+		}
+		p, err := decodeInt("p", k.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := decodeInt("q", k.Q)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		key.Precompute()
+		return key, nil
+	case *ecdsa.PublicKey:
+		d, err := decodeInt("d", k.D)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PrivateKey{PublicKey: *pub, D: d}
+		if x, y := pub.Curve.ScalarBaseMult(d.Bytes()); x.Cmp(pub.X) != 0 || y.Cmp(pub.Y) != 0 {
+			return nil, errors.New("EC private key does not match public key")
+		}
+		return key, nil
+	case ed25519.PublicKey:
+		seed, err := b64.DecodeString(k.D)
+		if err != nil {
+			return nil, fmt.Errorf("JWK field %q: %v", "d", err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key size")
+		}
+		key := ed25519.NewKeyFromSeed(seed)
+		if !pub.Equal(key.Public()) {
+			return nil, errors.New("Ed25519 private key does not match public key")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
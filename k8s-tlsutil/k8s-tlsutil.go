@@ -1,6 +1,7 @@
 package k8stlsutil
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -10,33 +11,73 @@ import (
 	"math"
 	"math/big"
 	"net"
+	"net/url"
 	"time"
 )
 
 const (
 	RSAKeySize   = 2048
 	Duration365d = time.Hour * 24 * 365
+
+	// Backdate is how far before the current time certificates become
+	// valid by default, to tolerate clock skew between machines.
+	Backdate = 5 * time.Minute
 )
 
 type CertConfig struct {
-	CommonName   string
+	CommonName string
+	// Organization of a certificate signed by NewSignedCertificate
+	// defaults to that of the CA.
 	Organization []string
 	AltNames     AltNames
+
+	// KeyUsage and ExtKeyUsage restrict what a leaf certificate may be used
+	// for. KeyUsage defaults to digital signature, plus key encipherment for
+	// RSA keys, and ExtKeyUsage to both server and client authentication.
+	// Both are ignored for CA certificates.
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+
+	// NotBefore defaults to Backdate before the time of issuance. NotAfter,
+	// if set, takes precedence over the validity duration passed to the
+	// certificate constructors.
+	NotBefore time.Time
+	NotAfter  time.Time
 }
 
-// AltNames contains the domain names and IP addresses that will be added
-// to the API Server's x509 certificate SubAltNames field. The values will
-// be passed directly to the x509.Certificate object.
+// validity returns the validity period for a certificate issued now with the
+// given default duration.
+func (cfg CertConfig) validity(dur time.Duration) (notBefore, notAfter time.Time) {
+	now := time.Now()
+	notBefore, notAfter = cfg.NotBefore, cfg.NotAfter
+	if notBefore.IsZero() {
+		notBefore = now.Add(-Backdate)
+	}
+	if notAfter.IsZero() {
+		notAfter = now.Add(dur)
+	}
+	return notBefore, notAfter
+}
+
+// AltNames contains the domain names, IP addresses, email addresses and URIs
+// that will be added to the API Server's x509 certificate SubAltNames field.
+// The values will be passed directly to the x509.Certificate object. URIs
+// carry workload identities such as SPIFFE IDs
+// ("spiffe://cluster.local/ns/default/sa/web").
 type AltNames struct {
-	DNSNames []string
-	IPs      []net.IP
+	DNSNames       []string
+	IPs            []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
 }
 
+// NewPrivateKey generates an RSA key of RSAKeySize bits. Use NewKey for
+// other sizes and key types.
 func NewPrivateKey() (*rsa.PrivateKey, error) {
 	return rsa.GenerateKey(rand.Reader, RSAKeySize)
 }
 
-func EncodePublicKeyPEM(key *rsa.PublicKey) ([]byte, error) {
+func EncodePublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
 	der, err := x509.MarshalPKIXPublicKey(key)
 	if err != nil {
 		return []byte{}, err
@@ -64,13 +105,12 @@ func EncodeCertificatePEM(cert *x509.Certificate) []byte {
 	return pem.EncodeToMemory(&block)
 }
 
-func NewSelfSignedCACertificate(cfg CertConfig, key *rsa.PrivateKey, validDuration time.Duration) (*x509.Certificate, error) {
-	now := time.Now()
-
+func NewSelfSignedCACertificate(cfg CertConfig, key crypto.Signer, validDuration time.Duration, opts ...CertOption) (*x509.Certificate, error) {
 	dur := Duration365d * 10
 	if validDuration != 0 {
 		dur = validDuration
 	}
+	notBefore, notAfter := cfg.validity(dur)
 
 	tmpl := x509.Certificate{
 		SerialNumber: new(big.Int).SetInt64(0),
@@ -78,13 +118,20 @@ func NewSelfSignedCACertificate(cfg CertConfig, key *rsa.PrivateKey, validDurati
 			CommonName:   cfg.CommonName,
 			Organization: cfg.Organization,
 		},
-		NotBefore:             now,
-		NotAfter:              now.Add(dur),
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage(key.Public()) | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
 		IsCA:                  true,
 	}
 
+	if err := setKeyIDs(&tmpl, key.Public(), nil); err != nil {
+		return nil, err
+	}
+	if err := applyCertOptions(&tmpl, opts); err != nil {
+		return nil, err
+	}
+
 	certDERBytes, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
 	if err != nil {
 		return nil, err
@@ -108,7 +155,7 @@ func ParsePEMEncodedPrivateKey(pemdata []byte) (*rsa.PrivateKey, error) {
 	return x509.ParsePKCS1PrivateKey(decoded.Bytes)
 }
 
-func NewSignedCertificate(cfg CertConfig, key *rsa.PrivateKey, caCert *x509.Certificate, caKey *rsa.PrivateKey, validDuration time.Duration) (*x509.Certificate, error) {
+func NewSignedCertificate(cfg CertConfig, key crypto.Signer, caCert *x509.Certificate, caKey crypto.Signer, validDuration time.Duration, opts ...CertOption) (*x509.Certificate, error) {
 	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
 	if err != nil {
 		return nil, err
@@ -118,19 +165,39 @@ func NewSignedCertificate(cfg CertConfig, key *rsa.PrivateKey, caCert *x509.Cert
 	if validDuration != 0 {
 		dur = validDuration
 	}
+	notBefore, notAfter := cfg.validity(dur)
+
+	org := cfg.Organization
+	if len(org) == 0 {
+		org = caCert.Subject.Organization
+	}
 
 	certTmpl := x509.Certificate{
 		Subject: pkix.Name{
 			CommonName:   cfg.CommonName,
-			Organization: caCert.Subject.Organization,
+			Organization: org,
 		},
-		DNSNames:     cfg.AltNames.DNSNames,
-		IPAddresses:  cfg.AltNames.IPs,
-		SerialNumber: serial,
-		NotBefore:    caCert.NotBefore,
-		NotAfter:     time.Now().Add(dur),
-		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:       cfg.AltNames.DNSNames,
+		IPAddresses:    cfg.AltNames.IPs,
+		EmailAddresses: cfg.AltNames.EmailAddresses,
+		URIs:           cfg.AltNames.URIs,
+		SerialNumber:   serial,
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+		KeyUsage:       keyUsage(key.Public()),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if cfg.KeyUsage != 0 {
+		certTmpl.KeyUsage = cfg.KeyUsage
+	}
+	if len(cfg.ExtKeyUsage) != 0 {
+		certTmpl.ExtKeyUsage = cfg.ExtKeyUsage
+	}
+	if err := setKeyIDs(&certTmpl, key.Public(), caCert); err != nil {
+		return nil, err
+	}
+	if err := applyCertOptions(&certTmpl, opts); err != nil {
+		return nil, err
 	}
 	certDERBytes, err := x509.CreateCertificate(rand.Reader, &certTmpl, caCert, key.Public(), caKey)
 	if err != nil {
@@ -0,0 +1,1161 @@
+package k8stlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// must fails the test if err is not nil.
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// newTestKey generates a key with opts.
+func newTestKey(t *testing.T, opts ...KeyOption) crypto.Signer {
+	t.Helper()
+	key, err := NewKey(opts...)
+	must(t, err)
+	return key
+}
+
+// newTestCA generates a key with opts and a self-signed CA certificate for
+// it from cfg.
+func newTestCA(t *testing.T, cfg CertConfig, opts ...KeyOption) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key := newTestKey(t, opts...)
+	ca, err := NewSelfSignedCACertificate(cfg, key, 0)
+	must(t, err)
+	return ca, key
+}
+
+// newTestCert is NewSignedCertificate, failing the test on error.
+func newTestCert(t *testing.T, cfg CertConfig, key crypto.Signer, ca *x509.Certificate, caKey crypto.Signer, dur time.Duration, opts ...CertOption) *x509.Certificate {
+	t.Helper()
+	cert, err := NewSignedCertificate(cfg, key, ca, caKey, dur, opts...)
+	must(t, err)
+	return cert
+}
+
+// tempDir creates a directory that is removed when the test ends.
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "k8s-tlsutil")
+	must(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestEd25519Issuance(t *testing.T) {
+	caKey, err := NewEd25519PrivateKey()
+	must(t, err)
+	ca, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, caKey, 0)
+	must(t, err)
+	key, err := NewEd25519PrivateKey()
+	must(t, err)
+	cert := newTestCert(t, CertConfig{
+		CommonName: "leaf",
+		AltNames:   AltNames{DNSNames: []string{"leaf.example.com"}},
+	}, key, ca, caKey, 0)
+	if cert.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Errorf("want Ed25519 public key, got %v", cert.PublicKeyAlgorithm)
+	}
+	if cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+		t.Errorf("Ed25519 certificate allows key encipherment")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "leaf.example.com", Roots: roots}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	pemKey, err := EncodePKCS8PrivateKeyPEM(key)
+	must(t, err)
+	parsed, err := ParsePEMEncodedKey(pemKey)
+	must(t, err)
+	if !reflect.DeepEqual(key, parsed) {
+		t.Errorf("PKCS#8 round trip: want=%v got=%v", key, parsed)
+	}
+}
+
+func TestSignCSR(t *testing.T) {
+	caKey, err := NewPrivateKey()
+	must(t, err)
+	ca, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, caKey, 0)
+	must(t, err)
+	key, err := NewEd25519PrivateKey()
+	must(t, err)
+	csr, err := NewCertificateRequest(CertConfig{
+		CommonName:   "system:node:n1",
+		Organization: []string{"system:nodes"},
+	}, key)
+	must(t, err)
+	cert, err := SignCSR(csr, ca, caKey, SigningProfile{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	must(t, err)
+	if cert.Subject.CommonName != "system:node:n1" || !reflect.DeepEqual(cert.Subject.Organization, []string{"system:nodes"}) {
+		t.Errorf("unexpected subject %v", cert.Subject)
+	}
+	if want := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}; !reflect.DeepEqual(want, cert.ExtKeyUsage) {
+		t.Errorf("want=%v got=%v", want, cert.ExtKeyUsage)
+	}
+	if err := cert.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	csr[len(csr)/2] ^= 1
+	if _, err := SignCSR(csr, ca, caKey, SigningProfile{}); err == nil {
+		t.Errorf("expected error for corrupted CSR")
+	}
+}
+
+func TestEncryptedPrivateKey(t *testing.T) {
+	key, err := NewPrivateKey()
+	must(t, err)
+	data, err := EncodeEncryptedPrivateKeyPEM(key, []byte("s3cret"))
+	must(t, err)
+	parsed, err := ParsePEMEncodedEncryptedKey(data, []byte("s3cret"))
+	must(t, err)
+	if !reflect.DeepEqual(key, parsed) {
+		t.Errorf("decrypted key does not match")
+	}
+	if _, err := ParsePEMEncodedEncryptedKey(data, []byte("wrong")); err == nil {
+		t.Errorf("expected error for wrong passphrase")
+	}
+}
+
+// reencodeEncryptedKey re-encodes the PKCS#8 encrypted key in data, letting
+// edit change its parameters.
+func reencodeEncryptedKey(t *testing.T, data []byte, edit func(iv, encrypted *[]byte, kdf *pbkdf2Params)) []byte {
+	block, _ := pem.Decode(data)
+	var info encryptedPrivateKeyInfo
+	var params pbes2Params
+	var kdf pbkdf2Params
+	var iv []byte
+	unmarshal := func(der []byte, v interface{}) {
+		_, err := asn1.Unmarshal(der, v)
+		must(t, err)
+	}
+	unmarshal(block.Bytes, &info)
+	unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+
+	edit(&iv, &info.EncryptedData, &kdf)
+
+	marshal := func(v interface{}) []byte {
+		der, err := asn1.Marshal(v)
+		must(t, err)
+		return der
+	}
+	params.KeyDerivationFunc.Parameters = asn1.RawValue{FullBytes: marshal(kdf)}
+	params.EncryptionScheme.Parameters = asn1.RawValue{FullBytes: marshal(iv)}
+	info.Algorithm.Parameters = asn1.RawValue{FullBytes: marshal(params)}
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: marshal(info)})
+}
+
+func TestEncryptedPrivateKeyInvalid(t *testing.T) {
+	key, err := NewPrivateKey()
+	must(t, err)
+	data, err := EncodeEncryptedPrivateKeyPEM(key, []byte("s3cret"))
+	must(t, err)
+
+	for i, edit := range []func(iv, encrypted *[]byte, kdf *pbkdf2Params){
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *encrypted = nil },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *encrypted = (*encrypted)[:len(*encrypted)-16] },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *encrypted = (*encrypted)[:len(*encrypted)-1] },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *iv = nil },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *iv = (*iv)[:8] },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { *iv = append(*iv, 0) },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { kdf.IterationCount = 0 },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { kdf.IterationCount = 1 << 30 },
+		func(iv, encrypted *[]byte, kdf *pbkdf2Params) { kdf.KeyLength = 1 << 30 },
+	} {
+		if _, err := ParsePEMEncodedEncryptedKey(reencodeEncryptedKey(t, data, edit), []byte("s3cret")); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+
+	// The unedited key still decrypts after re-encoding.
+	same := reencodeEncryptedKey(t, data, func(iv, encrypted *[]byte, kdf *pbkdf2Params) {})
+	if _, err := ParsePEMEncodedEncryptedKey(same, []byte("s3cret")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		opts []KeyOption
+		alg  x509.PublicKeyAlgorithm
+		bits int
+	}{
+		{nil, x509.RSA, RSAKeySize},
+		{[]KeyOption{WithRSAKey(3072)}, x509.RSA, 3072},
+		{[]KeyOption{WithECDSAKey(elliptic.P384())}, x509.ECDSA, 384},
+		{[]KeyOption{WithEd25519Key()}, x509.Ed25519, 0},
+	}
+	for i, tt := range tests {
+		key, err := NewKey(tt.opts...)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		cert, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, key, 0)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if cert.PublicKeyAlgorithm != tt.alg {
+			t.Errorf("case %d: want=%v got=%v", i, tt.alg, cert.PublicKeyAlgorithm)
+		}
+		var bits int
+		switch pub := key.Public().(type) {
+		case *rsa.PublicKey:
+			bits = pub.N.BitLen()
+		case *ecdsa.PublicKey:
+			bits = pub.Curve.Params().BitSize
+		}
+		if bits != tt.bits {
+			t.Errorf("case %d: want %d bits, got %d", i, tt.bits, bits)
+		}
+	}
+
+	if _, err := NewKey(WithRSAKey(1024)); err == nil {
+		t.Errorf("expected error for 1024-bit RSA key")
+	}
+}
+
+func TestCertConfigUsage(t *testing.T) {
+	ca, caKey := newTestCA(t, CertConfig{CommonName: "ca"}, WithECDSAKey(elliptic.P256()))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := []struct {
+		usage      []x509.ExtKeyUsage
+		serverAuth bool
+		clientAuth bool
+	}{
+		{nil, true, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, false, true},
+		{[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, true, false},
+	}
+	for i, tt := range tests {
+		cert := newTestCert(t, CertConfig{CommonName: "leaf", ExtKeyUsage: tt.usage}, caKey, ca, caKey, 0)
+		for _, v := range []struct {
+			usage x509.ExtKeyUsage
+			want  bool
+		}{
+			{x509.ExtKeyUsageServerAuth, tt.serverAuth},
+			{x509.ExtKeyUsageClientAuth, tt.clientAuth},
+		} {
+			_, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{v.usage}})
+			if got := err == nil; got != v.want {
+				t.Errorf("case %d: usage %v: want valid=%t got err=%v", i, v.usage, v.want, err)
+			}
+		}
+	}
+}
+
+func TestCertConfigValidity(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithEd25519Key())
+	if d := time.Since(ca.NotBefore); d < Backdate-time.Minute || d > Backdate+time.Minute {
+		t.Errorf("default NotBefore is %v in the past, want about %v", d, Backdate)
+	}
+
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(48 * time.Hour)
+	cert := newTestCert(t, CertConfig{
+		CommonName: "leaf",
+		NotBefore:  notBefore,
+		NotAfter:   notAfter,
+	}, key, ca, key, time.Hour)
+	if !cert.NotBefore.Equal(notBefore) || !cert.NotAfter.Equal(notAfter) {
+		t.Errorf("want validity %v-%v, got %v-%v", notBefore, notAfter, cert.NotBefore, cert.NotAfter)
+	}
+}
+
+func TestAltNamesURIsAndEmails(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithEd25519Key())
+	id, _ := url.Parse("spiffe://cluster.local/ns/default/sa/web")
+	cfg := CertConfig{
+		CommonName: "web",
+		AltNames: AltNames{
+			EmailAddresses: []string{"web@example.com"},
+			URIs:           []*url.URL{id},
+		},
+	}
+
+	direct := newTestCert(t, cfg, key, ca, key, 0)
+	csr, err := NewCertificateRequest(cfg, key)
+	must(t, err)
+	signed, err := SignCSR(csr, ca, key, SigningProfile{})
+	must(t, err)
+	for i, cert := range []*x509.Certificate{direct, signed} {
+		if !reflect.DeepEqual(cert.EmailAddresses, cfg.AltNames.EmailAddresses) {
+			t.Errorf("case %d: want=%v got=%v", i, cfg.AltNames.EmailAddresses, cert.EmailAddresses)
+		}
+		if len(cert.URIs) != 1 || cert.URIs[0].String() != id.String() {
+			t.Errorf("case %d: want=%v got=%v", i, id, cert.URIs)
+		}
+	}
+}
+
+func TestIntermediateChain(t *testing.T) {
+	root, rootKey := newTestCA(t, CertConfig{CommonName: "root"}, WithECDSAKey(elliptic.P256()))
+	interKey := newTestKey(t, WithECDSAKey(elliptic.P256()))
+	inter, err := NewSignedCACertificate(CertConfig{CommonName: "intermediate"}, interKey, root, rootKey, 0, 0)
+	must(t, err)
+	if !inter.IsCA || inter.MaxPathLen != 0 || !inter.MaxPathLenZero {
+		t.Errorf("unexpected CA constraints: IsCA=%t MaxPathLen=%d", inter.IsCA, inter.MaxPathLen)
+	}
+	leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, interKey, inter, interKey, 0)
+
+	chain, err := BuildChain(leaf, root, inter)
+	must(t, err)
+	if want := []*x509.Certificate{leaf, inter, root}; !reflect.DeepEqual(want, chain) {
+		t.Errorf("chain out of order: got %d certificates", len(chain))
+	}
+
+	var got []string
+	rest := EncodeCertificateChainPEM(chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		must(t, err)
+		got = append(got, c.Subject.CommonName)
+	}
+	if want := []string{"leaf", "intermediate", "root"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(inter)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithEd25519Key())
+	leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, key, ca, key, 24*time.Hour)
+	expired := newTestCert(t, CertConfig{
+		CommonName: "expired",
+		NotBefore:  time.Now().Add(-2 * time.Hour),
+		NotAfter:   time.Now().Add(-time.Hour),
+	}, key, ca, key, 0)
+
+	tests := []struct {
+		cert    *x509.Certificate
+		within  time.Duration
+		expired bool
+		expires bool
+	}{
+		{leaf, time.Hour, false, false},
+		{leaf, 48 * time.Hour, false, true},
+		{expired, 0, true, true},
+	}
+	for i, tt := range tests {
+		if got := IsExpired(tt.cert); got != tt.expired {
+			t.Errorf("case %d: IsExpired: want=%t got=%t", i, tt.expired, got)
+		}
+		if got := ExpiresWithin(tt.cert, tt.within); got != tt.expires {
+			t.Errorf("case %d: ExpiresWithin: want=%t got=%t", i, tt.expires, got)
+		}
+	}
+
+	bundle := EncodeCertificateChainPEM([]*x509.Certificate{ca, leaf})
+	soonest, err := SoonestExpiry(bundle)
+	must(t, err)
+	if soonest.Subject.CommonName != "leaf" {
+		t.Errorf("want soonest expiry leaf, got %s", soonest.Subject.CommonName)
+	}
+	if _, err := SoonestExpiry(nil); err == nil {
+		t.Errorf("expected error for empty bundle")
+	}
+}
+
+func TestJSONWebKeyRoundTrip(t *testing.T) {
+	opts := [][]KeyOption{
+		{WithRSAKey(2048)},
+		{WithECDSAKey(elliptic.P384())},
+		{WithEd25519Key()},
+	}
+	for i, o := range opts {
+		key := newTestKey(t, o...)
+		jwk, err := NewJSONWebKey(key)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		data, err := json.Marshal(jwk)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		var decoded JSONWebKey
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		priv, err := decoded.PrivateKey()
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		want, _ := x509.MarshalPKCS8PrivateKey(key)
+		got, _ := x509.MarshalPKCS8PrivateKey(priv)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("case %d: private key does not round trip", i)
+		}
+
+		pubJWK, err := NewJSONWebKey(key.Public())
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if pubJWK.IsPrivate() {
+			t.Errorf("case %d: public JWK holds private material", i)
+		}
+		pub, err := pubJWK.PublicKey()
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(key.Public(), pub) {
+			t.Errorf("case %d: public key does not round trip", i)
+		}
+	}
+}
+
+func TestKeyIdentifiers(t *testing.T) {
+	ca, caKey := newTestCA(t, CertConfig{CommonName: "ca"}, WithECDSAKey(elliptic.P256()))
+	key := newTestKey(t, WithRSAKey(2048))
+	leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, key, ca, caKey, 0)
+
+	caID, _ := SubjectKeyID(caKey.Public())
+	leafID, _ := SubjectKeyID(key.Public())
+	for _, tt := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"CA SKI", ca.SubjectKeyId, caID},
+		{"leaf SKI", leaf.SubjectKeyId, leafID},
+		{"leaf AKI", leaf.AuthorityKeyId, caID},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: want=%x got=%x", tt.name, tt.want, tt.got)
+		}
+	}
+
+	fp := FingerprintSHA256(leaf)
+	if s := FormatFingerprint(fp[:]); len(s) != 32*3-1 || strings.ToUpper(s) != s {
+		t.Errorf("unexpected fingerprint format %q", s)
+	}
+	if got := FormatFingerprint([]byte{0x0a, 0xbc}); got != "0A:BC" {
+		t.Errorf("want=0A:BC got=%s", got)
+	}
+}
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	keyPEM, certPEM, err := GenerateSelfSignedCert([]string{"localhost", "127.0.0.1"}, time.Hour)
+	must(t, err)
+	_, err = tls.X509KeyPair(certPEM, keyPEM)
+	must(t, err)
+	cert, err := ParsePEMEncodedCACert(certPEM)
+	must(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("verify %s: %v", host, err)
+		}
+	}
+	if _, _, err := GenerateSelfSignedCert(nil, 0); err == nil {
+		t.Errorf("expected error for no hosts")
+	}
+}
+
+func TestCertOptions(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithEd25519Key())
+	policy := asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	ext := pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Value: []byte{0x05, 0x00}}
+
+	tests := []struct {
+		opt   CertOption
+		field func(*x509.Certificate) interface{}
+		want  interface{}
+	}{
+		{
+			WithOCSPServers("http://ocsp.example.com"),
+			func(c *x509.Certificate) interface{} { return c.OCSPServer },
+			[]string{"http://ocsp.example.com"},
+		},
+		{
+			WithCRLDistributionPoints("http://crl.example.com/ca.crl"),
+			func(c *x509.Certificate) interface{} { return c.CRLDistributionPoints },
+			[]string{"http://crl.example.com/ca.crl"},
+		},
+		{
+			WithPolicyOIDs(policy),
+			func(c *x509.Certificate) interface{} { return c.PolicyIdentifiers },
+			[]asn1.ObjectIdentifier{policy},
+		},
+		{
+			WithExtensions(ext),
+			func(c *x509.Certificate) interface{} {
+				for _, e := range c.Extensions {
+					if e.Id.Equal(ext.Id) {
+						return e
+					}
+				}
+				return nil
+			},
+			ext,
+		},
+		{
+			// A custom option is a plain function.
+			func(c *x509.Certificate) { c.Subject.OrganizationalUnit = []string{"ops"} },
+			func(c *x509.Certificate) interface{} { return c.Subject.OrganizationalUnit },
+			[]string{"ops"},
+		},
+	}
+	for i, tt := range tests {
+		cert := newTestCert(t, CertConfig{CommonName: "leaf"}, key, ca, key, 0, tt.opt)
+		if got := tt.field(cert); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestMissingSANs(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithEd25519Key())
+	cert := newTestCert(t, CertConfig{
+		CommonName: "kube-apiserver",
+		AltNames: AltNames{
+			DNSNames: []string{"kubernetes.default", "*.example.com"},
+			IPs:      []net.IP{net.ParseIP("10.0.0.1")},
+		},
+	}, key, ca, key, 0)
+
+	tests := []struct {
+		hosts   []string
+		missing []string
+	}{
+		{[]string{"kubernetes.default", "10.0.0.1"}, nil},
+		{[]string{"api.example.com"}, nil},
+		{[]string{"a.b.example.com", "example.com"}, []string{"a.b.example.com", "example.com"}},
+		{[]string{"10.0.0.2", "kubernetes.default", "kube-apiserver"}, []string{"10.0.0.2", "kube-apiserver"}},
+	}
+	for i, tt := range tests {
+		if got := MissingSANs(cert, tt.hosts); !reflect.DeepEqual(tt.missing, got) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.missing, got)
+		}
+	}
+}
+
+func TestWriteKeyAndCert(t *testing.T) {
+	dir := tempDir(t)
+
+	keyPath := filepath.Join(dir, "tls.key")
+	certPath := filepath.Join(dir, "tls.crt")
+	// A pre-existing world-readable key must not stay world-readable.
+	must(t, ioutil.WriteFile(keyPath, []byte("old"), 0644))
+	keyPEM, certPEM, err := GenerateSelfSignedCert([]string{"localhost"}, 0)
+	must(t, err)
+	must(t, WriteKey(keyPath, keyPEM))
+	must(t, WriteCert(certPath, certPEM))
+
+	tests := []struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}{
+		{keyPath, keyPEM, 0600},
+		{certPath, certPEM, 0644},
+	}
+	for i, tt := range tests {
+		fi, err := os.Stat(tt.path)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if fi.Mode().Perm() != tt.mode {
+			t.Errorf("case %d: want mode %v, got %v", i, tt.mode, fi.Mode().Perm())
+		}
+		data, err := ioutil.ReadFile(tt.path)
+		if err != nil || !reflect.DeepEqual(data, tt.data) {
+			t.Errorf("case %d: unexpected contents (err=%v)", i, err)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	must(t, err)
+	if len(entries) != 2 {
+		t.Errorf("want 2 files, got %d: temporary files left behind", len(entries))
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	ca, key := newTestCA(t, CertConfig{CommonName: "ca", Organization: []string{"cluster"}}, WithEd25519Key())
+
+	tests := []struct {
+		cfg   CertConfig
+		cn    string
+		org   []string
+		usage []x509.ExtKeyUsage
+	}{
+		{KubeletClientConfig("n1"), "system:node:n1", []string{"system:nodes"}, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+		{AdminClientConfig(), "kubernetes-admin", []string{"system:masters"}, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+		// Profiles without an Organization get the CA's.
+		{APIServerConfig(AltNames{}), "kube-apiserver", []string{"cluster"}, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+		{EtcdPeerConfig("etcd-0", AltNames{}), "etcd-0", []string{"cluster"}, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}},
+	}
+	for i, tt := range tests {
+		cert := newTestCert(t, tt.cfg, key, ca, key, 0)
+		if cert.Subject.CommonName != tt.cn {
+			t.Errorf("case %d: want CN %q, got %q", i, tt.cn, cert.Subject.CommonName)
+		}
+		if !reflect.DeepEqual(cert.Subject.Organization, tt.org) {
+			t.Errorf("case %d: want O %v, got %v", i, tt.org, cert.Subject.Organization)
+		}
+		if !reflect.DeepEqual(cert.ExtKeyUsage, tt.usage) {
+			t.Errorf("case %d: want EKU %v, got %v", i, tt.usage, cert.ExtKeyUsage)
+		}
+	}
+}
+
+func TestRotator(t *testing.T) {
+	dir := tempDir(t)
+
+	ca, caKey := newTestCA(t, CertConfig{CommonName: "ca"}, WithECDSAKey(elliptic.P256()))
+	rotated := make(chan *tls.Certificate, 100)
+	cfg := RotatorConfig{
+		Config:      CertConfig{CommonName: "server"},
+		CACert:      ca,
+		CAKey:       caKey,
+		CertOptions: []CertOption{WithOCSPServers("http://ocsp.example.com")},
+		KeyOptions:  []KeyOption{WithECDSAKey(elliptic.P256())},
+		Duration:    2 * time.Second,
+		RenewBefore: 1500 * time.Millisecond,
+		CertFile:    filepath.Join(dir, "tls.crt"),
+		KeyFile:     filepath.Join(dir, "tls.key"),
+		OnRotate:    func(c *tls.Certificate) { rotated <- c },
+	}
+	r, err := NewRotator(cfg)
+	must(t, err)
+	first := <-rotated
+	r.Start()
+	select {
+	case <-rotated:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("certificate was not renewed")
+	}
+	r.Stop()
+
+	last := r.Current()
+	if last.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+		t.Errorf("renewed certificate has the same serial")
+	}
+	if want := []string{"http://ocsp.example.com"}; !reflect.DeepEqual(last.Leaf.OCSPServer, want) {
+		t.Errorf("want OCSP servers %v, got %v", want, last.Leaf.OCSPServer)
+	}
+	if got, _ := r.GetCertificate(nil); got != last {
+		t.Errorf("GetCertificate does not return the current certificate")
+	}
+	data, err := ioutil.ReadFile(cfg.CertFile)
+	must(t, err)
+	if !reflect.DeepEqual(data, EncodeCertificatePEM(last.Leaf)) {
+		t.Errorf("certificate file does not hold the current certificate")
+	}
+
+	// A certificate on disk that is not due for renewal is reused.
+	cfg.Duration = time.Hour
+	cfg.RenewBefore = 0
+	r.cfg = cfg
+	must(t, r.Rotate())
+	r2, err := NewRotator(cfg)
+	must(t, err)
+	if r2.Current().Leaf.SerialNumber.Cmp(r.Current().Leaf.SerialNumber) != 0 {
+		t.Errorf("certificate on disk was not reused")
+	}
+
+	// A certificate that cannot be replaced leaves the previous key in place.
+	oldKey, err := ioutil.ReadFile(cfg.KeyFile)
+	must(t, err)
+	r.cfg.CertFile = filepath.Join(dir, "certdir")
+	must(t, os.Mkdir(r.cfg.CertFile, 0755))
+	if err := r.Rotate(); err == nil {
+		t.Fatalf("Rotate replaced a directory")
+	}
+	if data, _ := ioutil.ReadFile(cfg.KeyFile); !reflect.DeepEqual(data, oldKey) {
+		t.Errorf("key file changed by a failed rotation")
+	}
+	if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+		t.Errorf("files no longer hold a matching pair: %v", err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".*.tmp*")); len(names) != 0 {
+		t.Errorf("temporary files left behind: %v", names)
+	}
+}
+
+func TestRotatorDue(t *testing.T) {
+	ca, caKey := newTestCA(t, CertConfig{CommonName: "ca"}, WithECDSAKey(elliptic.P256()))
+	cfg := RotatorConfig{
+		Config:      CertConfig{CommonName: "server"},
+		CACert:      ca,
+		CAKey:       caKey,
+		KeyOptions:  []KeyOption{WithECDSAKey(elliptic.P256())},
+		Duration:    time.Minute,
+		RenewBefore: time.Minute,
+	}
+	if _, err := NewRotator(cfg); err == nil {
+		t.Errorf("NewRotator accepted RenewBefore equal to Duration")
+	}
+
+	// A signer that only issues certificates that are already due is
+	// retried with a backoff rather than in a loop.
+	var mu sync.Mutex
+	var signed, failed int
+	cfg.Sign = func(c CertConfig, key crypto.Signer) (*x509.Certificate, error) {
+		mu.Lock()
+		signed++
+		mu.Unlock()
+		return NewSignedCertificate(c, key, ca, caKey, time.Minute)
+	}
+	cfg.RenewBefore = time.Hour
+	cfg.OnError = func(error) {
+		mu.Lock()
+		failed++
+		mu.Unlock()
+	}
+	r, err := NewRotator(cfg)
+	must(t, err)
+	r.Start()
+	time.Sleep(200 * time.Millisecond)
+	r.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if signed > 3 {
+		t.Errorf("want at most 3 certificates signed, got %d", signed)
+	}
+	if failed == 0 {
+		t.Errorf("OnError not called for a certificate that is already due")
+	}
+}
+
+func TestCAOptions(t *testing.T) {
+	key := newTestKey(t, WithECDSAKey(elliptic.P256()))
+	ca, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, key, 0,
+		WithRandomSerial(),
+		WithMaxPathLen(0),
+		WithPermittedDNSDomains("cluster.local"),
+	)
+	must(t, err)
+	if ca.SerialNumber.Sign() == 0 {
+		t.Errorf("want random serial, got 0")
+	}
+	if ca.MaxPathLen != 0 || !ca.MaxPathLenZero {
+		t.Errorf("want MaxPathLen 0, got %d", ca.MaxPathLen)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"api.cluster.local", true},
+		{"api.example.com", false},
+	}
+	for i, tt := range tests {
+		cert := newTestCert(t, CertConfig{
+			CommonName: tt.name,
+			AltNames:   AltNames{DNSNames: []string{tt.name}},
+		}, key, ca, key, 0)
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: tt.name, Roots: roots})
+		if got := err == nil; got != tt.ok {
+			t.Errorf("case %d: want valid=%t got err=%v", i, tt.ok, err)
+		}
+	}
+
+	inter, err := NewSignedCACertificate(CertConfig{CommonName: "intermediate"}, key, ca, key, -1, 0)
+	must(t, err)
+	leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, key, inter, key, 0)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(inter)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err == nil {
+		t.Errorf("expected path length violation")
+	}
+}
+
+func TestSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		key  []KeyOption
+		hash crypto.Hash
+		alg  x509.SignatureAlgorithm
+	}{
+		{[]KeyOption{WithRSAKey(2048)}, crypto.SHA384, x509.SHA384WithRSA},
+		{[]KeyOption{WithECDSAKey(elliptic.P256())}, crypto.SHA384, x509.ECDSAWithSHA384},
+		{[]KeyOption{WithECDSAKey(elliptic.P384())}, crypto.SHA512, x509.ECDSAWithSHA512},
+	}
+	for i, tt := range tests {
+		key := newTestKey(t, tt.key...)
+		alg, err := SignatureAlgorithmFor(key.Public(), tt.hash)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		ca, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, key, 0, WithSignatureAlgorithm(alg))
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, key, ca, key, 0, WithSignatureAlgorithm(alg))
+		for _, c := range []*x509.Certificate{ca, leaf} {
+			if c.SignatureAlgorithm != tt.alg {
+				t.Errorf("case %d: %s: want=%v got=%v", i, c.Subject.CommonName, tt.alg, c.SignatureAlgorithm)
+			}
+		}
+	}
+
+	if _, err := SignatureAlgorithmFor(nil, crypto.SHA256); err == nil {
+		t.Errorf("expected error for unsupported key")
+	}
+}
+
+func TestSSHInterop(t *testing.T) {
+	opts := [][]KeyOption{
+		{WithRSAKey(2048)},
+		{WithECDSAKey(elliptic.P256())},
+		{WithEd25519Key()},
+	}
+	for i, o := range opts {
+		key := newTestKey(t, o...)
+		data, err := EncodeOpenSSHPrivateKeyPEM(key, "test@example")
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		parsed, err := ssh.ParseRawPrivateKey(data)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		want, _ := x509.MarshalPKCS8PrivateKey(key)
+		var got []byte
+		switch p := parsed.(type) {
+		case *ed25519.PrivateKey:
+			got, _ = x509.MarshalPKCS8PrivateKey(*p)
+		default:
+			got, _ = x509.MarshalPKCS8PrivateKey(p)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("case %d: OpenSSH private key does not round trip", i)
+		}
+
+		line, err := SSHAuthorizedKey(key.Public(), "test@example")
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		pub, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if comment != "test@example" {
+			t.Errorf("case %d: want comment test@example, got %q", i, comment)
+		}
+
+		cert, err := NewSSHCertificate(SSHCertConfig{KeyID: "test", Principals: []string{"core"}}, pub, key)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		checker := ssh.CertChecker{
+			IsUserAuthority: func(auth ssh.PublicKey) bool {
+				return bytes.Equal(auth.Marshal(), pub.Marshal())
+			},
+		}
+		if _, err := checker.Authenticate(testConnMetadata("core"), cert); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if _, err := checker.Authenticate(testConnMetadata("root"), cert); err == nil {
+			t.Errorf("case %d: expected error for unlisted principal", i)
+		}
+	}
+}
+
+type testConnMetadata string
+
+func (m testConnMetadata) User() string        { return string(m) }
+func (testConnMetadata) SessionID() []byte     { return nil }
+func (testConnMetadata) ClientVersion() []byte { return nil }
+func (testConnMetadata) ServerVersion() []byte { return nil }
+func (testConnMetadata) RemoteAddr() net.Addr  { return nil }
+func (testConnMetadata) LocalAddr() net.Addr   { return nil }
+
+func TestCA(t *testing.T) {
+	cert, key := newTestCA(t, CertConfig{CommonName: "ca"}, WithECDSAKey(elliptic.P256()))
+	other := newTestKey(t, WithEd25519Key())
+	if _, err := NewCA(cert, other); err == nil {
+		t.Errorf("expected error for mismatched key")
+	}
+
+	ca, err := NewCA(cert, key)
+	must(t, err)
+	ca.KeyOptions = []KeyOption{WithEd25519Key()}
+	ca.SetLastSerial(big.NewInt(100))
+	var recorded []int64
+	ca.OnIssue = func(c *x509.Certificate) error {
+		recorded = append(recorded, c.SerialNumber.Int64())
+		return nil
+	}
+
+	// The calls share an options slice with spare capacity, which Issue must
+	// not write to.
+	opts := make([]CertOption, 1, 2)
+	opts[0] = func(c *x509.Certificate) { c.Subject.Organization = []string{"leaves"} }
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := ca.Issue(CertConfig{CommonName: "leaf"}, opts...); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if opts[:2][1] != nil {
+		t.Errorf("Issue wrote to the spare capacity of its options")
+	}
+
+	csr, err := NewCertificateRequest(CertConfig{CommonName: "csr"}, other)
+	must(t, err)
+	issued, err := ca.IssueCSR(csr, SigningProfile{})
+	must(t, err)
+	if err := issued.CheckSignatureFrom(cert); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	sort.Slice(recorded, func(i, j int) bool { return recorded[i] < recorded[j] })
+	if want := []int64{101, 102, 103, 104, 105}; !reflect.DeepEqual(want, recorded) {
+		t.Errorf("serials: want=%v got=%v", want, recorded)
+	}
+	if got := ca.LastSerial().Int64(); got != 105 {
+		t.Errorf("last serial: want=105 got=%d", got)
+	}
+}
+
+func TestKeyPair(t *testing.T) {
+	root, rootKey := newTestCA(t, CertConfig{CommonName: "root"}, WithECDSAKey(elliptic.P256()))
+	inter, err := NewSignedCACertificate(CertConfig{CommonName: "intermediate"}, rootKey, root, rootKey, 0, 0)
+	must(t, err)
+	key := newTestKey(t, WithEd25519Key())
+	leaf := newTestCert(t, CertConfig{CommonName: "leaf"}, key, inter, rootKey, 0)
+
+	kp := &KeyPair{Cert: leaf, Key: key, Chain: []*x509.Certificate{inter}}
+	must(t, kp.Validate())
+	certPEM, keyPEM, err := kp.EncodePEM()
+	must(t, err)
+	parsed, err := ParseKeyPairPEM(certPEM, keyPEM)
+	must(t, err)
+	if !reflect.DeepEqual(kp, parsed) {
+		t.Errorf("key pair does not round trip")
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if tc := parsed.TLSCertificate(); len(tc.Certificate) != 2 {
+		t.Errorf("want 2 certificates in TLS chain, got %d", len(tc.Certificate))
+	}
+
+	bad := []*KeyPair{
+		{Cert: leaf, Key: rootKey},
+		{Cert: leaf, Key: key, Chain: []*x509.Certificate{root}},
+	}
+	for i, kp := range bad {
+		if err := kp.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestKubernetesCSR(t *testing.T) {
+	key := newTestKey(t, WithECDSAKey(elliptic.P256()))
+	spec, err := NewKubernetesCSRSpec(KubeletClientConfig("n1"), key, SignerKubeAPIServerClientKubelet, time.Hour)
+	must(t, err)
+	if want := []string{"digital signature", "client auth"}; !reflect.DeepEqual(want, spec.Usages) {
+		t.Errorf("usages: want=%v got=%v", want, spec.Usages)
+	}
+	if spec.ExpirationSeconds == nil || *spec.ExpirationSeconds != 3600 {
+		t.Errorf("unexpected expirationSeconds %v", spec.ExpirationSeconds)
+	}
+	data, err := json.Marshal(spec)
+	must(t, err)
+	if !strings.Contains(string(data), `"signerName":"kubernetes.io/kube-apiserver-client-kubelet"`) {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	for i, tt := range []struct {
+		duration time.Duration
+		want     *int32
+		wantErr  bool
+	}{
+		{0, nil, false},
+		{10 * time.Minute, int32Ptr(600), false},
+		{time.Duration(math.MaxInt32) * time.Second, int32Ptr(math.MaxInt32), false},
+		{10*time.Minute - time.Second, nil, true},
+		{-time.Hour, nil, true},
+		{time.Duration(math.MaxInt32+1) * time.Second, nil, true},
+	} {
+		spec, err := NewKubernetesCSRSpec(EtcdClientConfig("c"), key, SignerKubeAPIServerClient, tt.duration)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(spec.ExpirationSeconds, tt.want) {
+			t.Errorf("case %d: want expirationSeconds=%v got=%v", i, tt.want, spec.ExpirationSeconds)
+		}
+	}
+
+	ca, err := NewSelfSignedCACertificate(CertConfig{CommonName: "ca"}, key, 0)
+	must(t, err)
+	cert, err := SignCSR(spec.Request, ca, key, SigningProfile{})
+	must(t, err)
+	issued, _ := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"certificate": EncodeCertificatePEM(cert),
+			"conditions":  []map[string]string{{"type": "Approved"}},
+		},
+	})
+
+	tests := []struct {
+		data    string
+		wantErr bool
+	}{
+		{string(issued), false},
+		{`{"status":{}}`, true},
+		{`{"status":{"conditions":[{"type":"Denied","reason":"NotAllowed"}]}}`, true},
+	}
+	for i, tt := range tests {
+		certs, err := ParseKubernetesCSRCertificate([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !tt.wantErr && (len(certs) != 1 || !certs[0].Equal(cert)) {
+			t.Errorf("case %d: issued certificate not returned", i)
+		}
+	}
+	if _, err := ParseKubernetesCSRCertificate([]byte(`{"status":{}}`)); err != ErrCSRPending {
+		t.Errorf("want ErrCSRPending, got %v", err)
+	}
+}
+
+func TestMaterializePKI(t *testing.T) {
+	dir := tempDir(t)
+
+	spec := []byte(`
+ca:
+  commonName: cluster-ca
+  keyType: ecdsa
+certs:
+- name: apiserver
+  commonName: kube-apiserver
+  dnsNames: [kubernetes.default]
+  ips: [10.3.0.1]
+  usages: [server auth]
+  duration: 720h
+  keyType: ed25519
+- name: admin
+  commonName: kubernetes-admin
+  organization: ["system:masters"]
+  usages: [client auth]
+  keyType: ecdsa
+  keySize: 384
+`)
+	must(t, MaterializePKI(spec, dir))
+
+	load := func(name string) *KeyPair {
+		certPEM, err := ioutil.ReadFile(filepath.Join(dir, name+".crt"))
+		must(t, err)
+		keyPEM, err := ioutil.ReadFile(filepath.Join(dir, name+".key"))
+		must(t, err)
+		kp, err := ParseKeyPairPEM(certPEM, keyPEM)
+		must(t, err)
+		if err := kp.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		return kp
+	}
+	ca := load("ca")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	api := load("apiserver")
+	if _, err := api.Cert.Verify(x509.VerifyOptions{DNSName: "10.3.0.1", Roots: roots}); err != nil {
+		t.Errorf("apiserver: %v", err)
+	}
+	if d := api.Cert.NotAfter.Sub(time.Now()); d > 720*time.Hour || d < 719*time.Hour {
+		t.Errorf("apiserver: unexpected lifetime %v", d)
+	}
+	admin := load("admin")
+	if _, err := admin.Cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("admin: %v", err)
+	}
+	if !reflect.DeepEqual(admin.Cert.Subject.Organization, []string{"system:masters"}) {
+		t.Errorf("admin: unexpected subject %v", admin.Cert.Subject)
+	}
+
+	// Applying the spec again keeps the existing files.
+	must(t, MaterializePKI(spec, dir))
+	if again := load("apiserver"); !again.Cert.Equal(api.Cert) {
+		t.Errorf("apiserver certificate was reissued")
+	}
+
+	if err := MaterializePKI([]byte(`{"ca": {"commonName": "ca"}, "certs": [{"commonName": "x"}]}`), dir); err == nil {
+		t.Errorf("expected error for certificate without name")
+	}
+}
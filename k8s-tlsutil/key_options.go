@@ -0,0 +1,101 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+)
+
+// KeyType is the algorithm of a generated private key.
+type KeyType int
+
+const (
+	KeyTypeRSA KeyType = iota
+	KeyTypeECDSA
+	KeyTypeEd25519
+)
+
+// KeyOptions describes the private key NewKey generates. The zero value, or
+// DefaultKeyOptions, selects an RSAKeySize-bit RSA key.
+type KeyOptions struct {
+	Type KeyType
+	// RSABits is the RSA modulus size: 2048, 3072 or 4096. Zero means
+	// RSAKeySize.
+	RSABits int
+	// Curve is the ECDSA curve: P-256, P-384 or P-521. Nil means P-256.
+	Curve elliptic.Curve
+}
+
+// DefaultKeyOptions are the options NewPrivateKey uses.
+var DefaultKeyOptions = KeyOptions{Type: KeyTypeRSA, RSABits: RSAKeySize}
+
+// KeyOption modifies KeyOptions.
+type KeyOption func(*KeyOptions)
+
+// WithRSAKey selects an RSA key of the given size.
+func WithRSAKey(bits int) KeyOption {
+	return func(o *KeyOptions) {
+		o.Type = KeyTypeRSA
+		o.RSABits = bits
+	}
+}
+
+// WithECDSAKey selects an ECDSA key on the given curve.
+func WithECDSAKey(curve elliptic.Curve) KeyOption {
+	return func(o *KeyOptions) {
+		o.Type = KeyTypeECDSA
+		o.Curve = curve
+	}
+}
+
+// WithEd25519Key selects an Ed25519 key.
+func WithEd25519Key() KeyOption {
+	return func(o *KeyOptions) {
+		o.Type = KeyTypeEd25519
+	}
+}
+
+// NewKey generates a private key as described by opts, applied in order on
+// top of DefaultKeyOptions. The key can be passed to the certificate
+// constructors, and encoded with EncodePKCS8PrivateKeyPEM.
+func NewKey(opts ...KeyOption) (crypto.Signer, error) {
+	o := DefaultKeyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.NewKey()
+}
+
+// NewKey generates a private key as described by o.
+func (o KeyOptions) NewKey() (crypto.Signer, error) {
+	switch o.Type {
+	case KeyTypeRSA:
+		bits := o.RSABits
+		if bits == 0 {
+			bits = RSAKeySize
+		}
+		switch bits {
+		case 2048, 3072, 4096:
+		default:
+			return nil, fmt.Errorf("unsupported RSA key size %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeECDSA:
+		curve := o.Curve
+		if curve == nil {
+			curve = elliptic.P256()
+		}
+		switch curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", curve.Params().Name)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyTypeEd25519:
+		return NewEd25519PrivateKey()
+	}
+	return nil, fmt.Errorf("unsupported key type %d", o.Type)
+}
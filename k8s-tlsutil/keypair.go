@@ -0,0 +1,84 @@
+package k8stlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KeyPair bundles a certificate with its private key and the chain of
+// intermediate certificates that lead to a trusted root.
+type KeyPair struct {
+	Cert  *x509.Certificate
+	Key   crypto.Signer
+	Chain []*x509.Certificate
+}
+
+// ParseKeyPairPEM parses a PEM certificate bundle, leaf first followed by its
+// chain, and a PEM private key in any format ParsePEMEncodedKey supports.
+func ParseKeyPairPEM(certPEM, keyPEM []byte) (*KeyPair, error) {
+	certs, err := ParsePEMEncodedCerts(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePEMEncodedKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Cert: certs[0], Key: key, Chain: certs[1:]}, nil
+}
+
+// EncodePEM returns the certificate followed by its chain, and the PKCS#8
+// encoded key.
+func (kp *KeyPair) EncodePEM() (certPEM, keyPEM []byte, err error) {
+	keyPEM, err = EncodePKCS8PrivateKeyPEM(kp.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return EncodeCertificateChainPEM(append([]*x509.Certificate{kp.Cert}, kp.Chain...)), keyPEM, nil
+}
+
+// TLSCertificate returns the key pair for use in a tls.Config.
+func (kp *KeyPair) TLSCertificate() tls.Certificate {
+	cert := tls.Certificate{
+		Certificate: [][]byte{kp.Cert.Raw},
+		PrivateKey:  kp.Key,
+		Leaf:        kp.Cert,
+	}
+	for _, c := range kp.Chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert
+}
+
+// Validate checks that the key belongs to the certificate, that the
+// certificate is currently valid, and that each certificate of the chain
+// issued the one before it.
+func (kp *KeyPair) Validate() error {
+	if kp.Cert == nil || kp.Key == nil {
+		return errors.New("key pair is incomplete")
+	}
+	pub, ok := kp.Key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(kp.Cert.PublicKey) {
+		return errors.New("private key does not match certificate")
+	}
+	now := time.Now()
+	if now.Before(kp.Cert.NotBefore) || now.After(kp.Cert.NotAfter) {
+		return fmt.Errorf("certificate is valid from %v to %v", kp.Cert.NotBefore, kp.Cert.NotAfter)
+	}
+	child := kp.Cert
+	for i, c := range kp.Chain {
+		if !bytes.Equal(child.RawIssuer, c.RawSubject) {
+			return fmt.Errorf("chain certificate %d is not the issuer of the certificate before it", i)
+		}
+		if err := child.CheckSignatureFrom(c); err != nil {
+			return fmt.Errorf("chain certificate %d did not issue the certificate before it: %v", i, err)
+		}
+		child = c
+	}
+	return nil
+}
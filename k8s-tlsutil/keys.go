@@ -0,0 +1,67 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// NewEd25519PrivateKey generates an Ed25519 key. It can be used anywhere a
+// crypto.Signer is accepted, and encoded with EncodePKCS8PrivateKeyPEM.
+func NewEd25519PrivateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// EncodePKCS8PrivateKeyPEM encodes an RSA, ECDSA or Ed25519 private key as a
+// PKCS#8 "PRIVATE KEY" PEM block.
+func EncodePKCS8PrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	block := pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}
+	return pem.EncodeToMemory(&block), nil
+}
+
+// ParsePEMEncodedKey parses a PKCS#1 RSA, SEC 1 EC or PKCS#8 private key.
+func ParsePEMEncodedKey(pemdata []byte) (crypto.Signer, error) {
+	decoded, _ := pem.Decode(pemdata)
+	if decoded == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch decoded.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(decoded.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(decoded.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(decoded.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q", decoded.Type)
+}
+
+// keyUsage returns the key usages appropriate for a certificate holding pub.
+// Only RSA keys can be used for key encipherment.
+func keyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
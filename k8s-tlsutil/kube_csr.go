@@ -0,0 +1,141 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Signer names of the certificates.k8s.io API built into Kubernetes.
+const (
+	SignerKubeAPIServerClient        = "kubernetes.io/kube-apiserver-client"
+	SignerKubeAPIServerClientKubelet = "kubernetes.io/kube-apiserver-client-kubelet"
+	SignerKubeletServing             = "kubernetes.io/kubelet-serving"
+	SignerLegacyUnknown              = "kubernetes.io/legacy-unknown"
+)
+
+// minKubernetesCSRDuration is the shortest expirationSeconds the API server
+// accepts.
+const minKubernetesCSRDuration = 10 * time.Minute
+
+// KubernetesCSRSpec holds the spec of a certificates.k8s.io/v1
+// CertificateSigningRequest. It marshals to the same JSON, so it can be
+// embedded in an object submitted to the API server.
+type KubernetesCSRSpec struct {
+	Request           []byte   `json:"request"`
+	SignerName        string   `json:"signerName"`
+	Usages            []string `json:"usages,omitempty"`
+	ExpirationSeconds *int32   `json:"expirationSeconds,omitempty"`
+}
+
+var kubeKeyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital signature"},
+	{x509.KeyUsageContentCommitment, "content commitment"},
+	{x509.KeyUsageKeyEncipherment, "key encipherment"},
+	{x509.KeyUsageDataEncipherment, "data encipherment"},
+	{x509.KeyUsageKeyAgreement, "key agreement"},
+	{x509.KeyUsageCertSign, "cert sign"},
+	{x509.KeyUsageCRLSign, "crl sign"},
+	{x509.KeyUsageEncipherOnly, "encipher only"},
+	{x509.KeyUsageDecipherOnly, "decipher only"},
+}
+
+var kubeExtKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "server auth",
+	x509.ExtKeyUsageClientAuth:      "client auth",
+	x509.ExtKeyUsageCodeSigning:     "code signing",
+	x509.ExtKeyUsageEmailProtection: "email protection",
+	x509.ExtKeyUsageIPSECEndSystem:  "ipsec end system",
+	x509.ExtKeyUsageIPSECTunnel:     "ipsec tunnel",
+	x509.ExtKeyUsageIPSECUser:       "ipsec user",
+	x509.ExtKeyUsageTimeStamping:    "timestamping",
+	x509.ExtKeyUsageOCSPSigning:     "ocsp signing",
+}
+
+// NewKubernetesCSRSpec creates a certificate signing request for key from cfg
+// and returns it as the spec of a Kubernetes CertificateSigningRequest for
+// signerName. The usages are those of cfg, with the same defaults as
+// NewSignedCertificate. A non-zero duration requests a certificate lifetime,
+// which signers may honor from Kubernetes 1.22 on. Like the API server, it
+// rejects durations under ten minutes or beyond what expirationSeconds holds.
+func NewKubernetesCSRSpec(cfg CertConfig, key crypto.Signer, signerName string, duration time.Duration) (*KubernetesCSRSpec, error) {
+	if duration != 0 && (duration < minKubernetesCSRDuration || duration/time.Second > math.MaxInt32) {
+		return nil, fmt.Errorf("requested duration %v is outside [%v, %v]", duration, minKubernetesCSRDuration, time.Duration(math.MaxInt32)*time.Second)
+	}
+	csr, err := NewCertificateRequest(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ku := cfg.KeyUsage
+	if ku == 0 {
+		ku = keyUsage(key.Public())
+	}
+	eku := cfg.ExtKeyUsage
+	if len(eku) == 0 {
+		eku = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	spec := &KubernetesCSRSpec{
+		Request:    csr,
+		SignerName: signerName,
+	}
+	for _, u := range kubeKeyUsages {
+		if ku&u.usage != 0 {
+			spec.Usages = append(spec.Usages, u.name)
+		}
+	}
+	for _, u := range eku {
+		name, ok := kubeExtKeyUsages[u]
+		if !ok {
+			return nil, fmt.Errorf("extended key usage %d has no Kubernetes equivalent", u)
+		}
+		spec.Usages = append(spec.Usages, name)
+	}
+	if duration != 0 {
+		secs := int32(duration / time.Second)
+		spec.ExpirationSeconds = &secs
+	}
+	return spec, nil
+}
+
+// ErrCSRPending is returned by ParseKubernetesCSRCertificate when the request
+// has not been signed yet.
+var ErrCSRPending = errors.New("certificate signing request has not been issued yet")
+
+// ParseKubernetesCSRCertificate extracts the issued certificates from the
+// JSON of a CertificateSigningRequest object, as returned by the API server.
+// It fails if the request was denied or failed, and returns ErrCSRPending if
+// it has not been signed yet.
+func ParseKubernetesCSRCertificate(data []byte) ([]*x509.Certificate, error) {
+	var csr struct {
+		Status struct {
+			Certificate []byte `json:"certificate"`
+			Conditions  []struct {
+				Type    string `json:"type"`
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &csr); err != nil {
+		return nil, err
+	}
+	for _, c := range csr.Status.Conditions {
+		if c.Type == "Denied" || c.Type == "Failed" {
+			return nil, fmt.Errorf("certificate signing request %s: %s: %s", c.Type, c.Reason, c.Message)
+		}
+	}
+	if len(csr.Status.Certificate) == 0 {
+		return nil, ErrCSRPending
+	}
+	return ParsePEMEncodedCerts(csr.Status.Certificate)
+}
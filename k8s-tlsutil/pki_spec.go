@@ -0,0 +1,256 @@
+package k8stlsutil
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v1"
+)
+
+// PKISpec describes a CA and the leaf certificates it issues. It is read
+// from YAML or JSON by ParsePKISpec:
+//
+//	ca:
+//	  commonName: cluster-ca
+//	  duration: 87600h
+//	certs:
+//	- name: apiserver
+//	  commonName: kube-apiserver
+//	  dnsNames: [kubernetes, kubernetes.default]
+//	  ips: [10.3.0.1]
+//	  usages: [server auth]
+//	- name: admin
+//	  commonName: kubernetes-admin
+//	  organization: ["system:masters"]
+//	  usages: [client auth]
+//	  keyType: ecdsa
+type PKISpec struct {
+	CA    CertSpec   `yaml:"ca"`
+	Certs []CertSpec `yaml:"certs"`
+}
+
+// CertSpec describes one certificate of a PKISpec.
+type CertSpec struct {
+	// Name is the base name of the certificate and key files. It defaults
+	// to "ca" for the CA and is required for other certificates.
+	Name         string   `yaml:"name"`
+	CommonName   string   `yaml:"commonName"`
+	Organization []string `yaml:"organization"`
+	DNSNames     []string `yaml:"dnsNames"`
+	IPs          []string `yaml:"ips"`
+	Emails       []string `yaml:"emails"`
+	URIs         []string `yaml:"uris"`
+	// Usages are extended key usages in Kubernetes notation, such as
+	// "server auth" or "client auth". Defaults to both.
+	Usages []string `yaml:"usages"`
+	// Duration is a time.ParseDuration string such as "8760h".
+	Duration string `yaml:"duration"`
+	// KeyType is "rsa", "ecdsa" or "ed25519". Defaults to "rsa".
+	KeyType string `yaml:"keyType"`
+	// KeySize is the RSA modulus size, or the ECDSA curve size (256, 384
+	// or 521).
+	KeySize int `yaml:"keySize"`
+}
+
+// ParsePKISpec parses a PKISpec from YAML or JSON.
+func ParsePKISpec(data []byte) (*PKISpec, error) {
+	var spec PKISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	if spec.CA.Name == "" {
+		spec.CA.Name = "ca"
+	}
+	if spec.CA.CommonName == "" {
+		return nil, errors.New("CA has no commonName")
+	}
+	for i, c := range spec.Certs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("certificate %d has no name", i)
+		}
+	}
+	return &spec, nil
+}
+
+// MaterializePKI reads a PKISpec and writes the CA and every certificate it
+// describes to dir, as "<name>.crt" and "<name>.key". Existing files are
+// kept: an existing CA signs any missing certificates, and certificates whose
+// files exist are skipped, so the same spec can be applied repeatedly during
+// cluster bootstrap.
+func MaterializePKI(specData []byte, dir string) error {
+	spec, err := ParsePKISpec(specData)
+	if err != nil {
+		return err
+	}
+	return spec.Materialize(dir)
+}
+
+// Materialize writes the PKI described by s to dir, as MaterializePKI does.
+func (s *PKISpec) Materialize(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ca, err := s.loadOrCreateCA(dir)
+	if err != nil {
+		return err
+	}
+	for _, c := range s.Certs {
+		certPath, keyPath := specPaths(dir, c.Name)
+		if fileExists(certPath) && fileExists(keyPath) {
+			continue
+		}
+		cfg, dur, keyOpts, err := c.config()
+		if err != nil {
+			return fmt.Errorf("certificate %q: %v", c.Name, err)
+		}
+		key, err := NewKey(keyOpts...)
+		if err != nil {
+			return fmt.Errorf("certificate %q: %v", c.Name, err)
+		}
+		cert, err := NewSignedCertificate(cfg, key, ca.Cert, ca.Key, dur)
+		if err != nil {
+			return fmt.Errorf("certificate %q: %v", c.Name, err)
+		}
+		kp := &KeyPair{Cert: cert, Key: key}
+		if err := writeKeyPair(kp, certPath, keyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PKISpec) loadOrCreateCA(dir string) (*KeyPair, error) {
+	certPath, keyPath := specPaths(dir, s.CA.Name)
+	if fileExists(certPath) && fileExists(keyPath) {
+		certPEM, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		return ParseKeyPairPEM(certPEM, keyPEM)
+	}
+
+	cfg, dur, keyOpts, err := s.CA.config()
+	if err != nil {
+		return nil, fmt.Errorf("CA: %v", err)
+	}
+	key, err := NewKey(keyOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("CA: %v", err)
+	}
+	cert, err := NewSelfSignedCACertificate(cfg, key, dur, WithRandomSerial())
+	if err != nil {
+		return nil, fmt.Errorf("CA: %v", err)
+	}
+	kp := &KeyPair{Cert: cert, Key: key}
+	if err := writeKeyPair(kp, certPath, keyPath); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// config converts c to the arguments of the certificate constructors.
+func (c CertSpec) config() (CertConfig, time.Duration, []KeyOption, error) {
+	cfg := CertConfig{
+		CommonName:   c.CommonName,
+		Organization: c.Organization,
+		AltNames: AltNames{
+			DNSNames:       c.DNSNames,
+			EmailAddresses: c.Emails,
+		},
+	}
+	for _, s := range c.IPs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return cfg, 0, nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		cfg.AltNames.IPs = append(cfg.AltNames.IPs, ip)
+	}
+	for _, s := range c.URIs {
+		u, err := url.Parse(s)
+		if err != nil {
+			return cfg, 0, nil, err
+		}
+		cfg.AltNames.URIs = append(cfg.AltNames.URIs, u)
+	}
+	for _, name := range c.Usages {
+		u, ok := extKeyUsageByName(name)
+		if !ok {
+			return cfg, 0, nil, fmt.Errorf("unknown usage %q", name)
+		}
+		cfg.ExtKeyUsage = append(cfg.ExtKeyUsage, u)
+	}
+
+	var dur time.Duration
+	if c.Duration != "" {
+		var err error
+		if dur, err = time.ParseDuration(c.Duration); err != nil {
+			return cfg, 0, nil, err
+		}
+	}
+
+	var keyOpts []KeyOption
+	switch c.KeyType {
+	case "", "rsa":
+		keyOpts = append(keyOpts, WithRSAKey(c.KeySize))
+	case "ecdsa":
+		var curve elliptic.Curve
+		switch c.KeySize {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return cfg, 0, nil, fmt.Errorf("unsupported ECDSA key size %d", c.KeySize)
+		}
+		keyOpts = append(keyOpts, WithECDSAKey(curve))
+	case "ed25519":
+		keyOpts = append(keyOpts, WithEd25519Key())
+	default:
+		return cfg, 0, nil, fmt.Errorf("unknown key type %q", c.KeyType)
+	}
+	return cfg, dur, keyOpts, nil
+}
+
+func extKeyUsageByName(name string) (x509.ExtKeyUsage, bool) {
+	for u, n := range kubeExtKeyUsages {
+		if n == name {
+			return u, true
+		}
+	}
+	return 0, false
+}
+
+func specPaths(dir, name string) (certPath, keyPath string) {
+	return filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+}
+
+func writeKeyPair(kp *KeyPair, certPath, keyPath string) error {
+	certPEM, keyPEM, err := kp.EncodePEM()
+	if err != nil {
+		return err
+	}
+	if err := WriteKey(keyPath, keyPEM); err != nil {
+		return err
+	}
+	return WriteCert(certPath, certPEM)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
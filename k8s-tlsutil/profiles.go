@@ -0,0 +1,105 @@
+package k8stlsutil
+
+import (
+	"crypto/x509"
+)
+
+// Certificate profiles for the usual Kubernetes cluster components, following
+// the names and usages kubeadm generates. Each returns a CertConfig to pass to
+// NewSignedCertificate or NewCertificateRequest; the Organization of client
+// certificates is the group Kubernetes RBAC sees.
+
+func usages(u ...x509.ExtKeyUsage) []x509.ExtKeyUsage {
+	return u
+}
+
+// APIServerConfig is the kube-apiserver serving certificate. altNames should
+// include the service names ("kubernetes", "kubernetes.default", ...), the
+// service cluster IP and the addresses clients reach the apiserver on.
+func APIServerConfig(altNames AltNames) CertConfig {
+	return CertConfig{
+		CommonName:  "kube-apiserver",
+		AltNames:    altNames,
+		ExtKeyUsage: usages(x509.ExtKeyUsageServerAuth),
+	}
+}
+
+// APIServerKubeletClientConfig is the client certificate kube-apiserver uses
+// to reach kubelets.
+func APIServerKubeletClientConfig() CertConfig {
+	return CertConfig{
+		CommonName:   "kube-apiserver-kubelet-client",
+		Organization: []string{"system:masters"},
+		ExtKeyUsage:  usages(x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// AdminClientConfig is a cluster-admin client certificate.
+func AdminClientConfig() CertConfig {
+	return CertConfig{
+		CommonName:   "kubernetes-admin",
+		Organization: []string{"system:masters"},
+		ExtKeyUsage:  usages(x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// KubeletClientConfig is the client certificate of the kubelet on nodeName,
+// as authorized by the Node authorizer.
+func KubeletClientConfig(nodeName string) CertConfig {
+	return CertConfig{
+		CommonName:   "system:node:" + nodeName,
+		Organization: []string{"system:nodes"},
+		ExtKeyUsage:  usages(x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// KubeletServingConfig is the serving certificate of the kubelet on
+// nodeName.
+func KubeletServingConfig(nodeName string, altNames AltNames) CertConfig {
+	return CertConfig{
+		CommonName:   "system:node:" + nodeName,
+		Organization: []string{"system:nodes"},
+		AltNames:     altNames,
+		ExtKeyUsage:  usages(x509.ExtKeyUsageServerAuth),
+	}
+}
+
+// EtcdServerConfig is the client-facing certificate of the etcd member name.
+// Like kubeadm's, it is valid for client authentication too, which etcd uses
+// for its own gateway and grpc-proxy connections.
+func EtcdServerConfig(name string, altNames AltNames) CertConfig {
+	return CertConfig{
+		CommonName:  name,
+		AltNames:    altNames,
+		ExtKeyUsage: usages(x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// EtcdPeerConfig is the certificate the etcd member name uses for peer
+// traffic, both as server and as client.
+func EtcdPeerConfig(name string, altNames AltNames) CertConfig {
+	return CertConfig{
+		CommonName:  name,
+		AltNames:    altNames,
+		ExtKeyUsage: usages(x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// EtcdClientConfig is an etcd client certificate, such as the one
+// kube-apiserver uses ("kube-apiserver-etcd-client").
+func EtcdClientConfig(name string) CertConfig {
+	return CertConfig{
+		CommonName:  name,
+		ExtKeyUsage: usages(x509.ExtKeyUsageClientAuth),
+	}
+}
+
+// FrontProxyClientConfig is the client certificate kube-apiserver presents
+// to aggregated API servers. It must be signed by the front proxy CA, not the
+// cluster CA.
+func FrontProxyClientConfig() CertConfig {
+	return CertConfig{
+		CommonName:  "front-proxy-client",
+		ExtKeyUsage: usages(x509.ExtKeyUsageClientAuth),
+	}
+}
@@ -0,0 +1,266 @@
+package k8stlsutil
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// RotatorConfig configures a Rotator.
+type RotatorConfig struct {
+	// Config describes the certificate to keep issued.
+	Config CertConfig
+	// CACert and CAKey sign new certificates with NewSignedCertificate,
+	// unless Sign is set.
+	CACert *x509.Certificate
+	CAKey  crypto.Signer
+	// Sign, if set, issues a certificate for key instead, for example by
+	// submitting a CSR to a remote CA.
+	Sign func(cfg CertConfig, key crypto.Signer) (*x509.Certificate, error)
+	// CertOptions adjust certificates signed with CACert.
+	CertOptions []CertOption
+	// KeyOptions selects the type of the key generated for each
+	// certificate.
+	KeyOptions []KeyOption
+	// Duration is the validity of issued certificates when signed with
+	// CACert. Defaults to one year.
+	Duration time.Duration
+	// RenewBefore is how long before expiry a certificate is renewed.
+	// Defaults to a third of its lifetime. It must be shorter than
+	// Duration.
+	RenewBefore time.Duration
+	// CertFile and KeyFile, if set, are loaded on start when they hold a
+	// certificate that is not yet due for renewal, and are rewritten
+	// after every renewal. Both files are written before either is
+	// replaced, and the old key is restored if the certificate cannot be,
+	// so a failed renewal leaves the previous pair in place.
+	CertFile string
+	KeyFile  string
+	// MaxRetryBackoff bounds the wait between failed renewal attempts.
+	// Defaults to one minute.
+	MaxRetryBackoff time.Duration
+	// OnRotate, if set, is called after every renewal.
+	OnRotate func(*tls.Certificate)
+	// OnError, if set, is called when a renewal attempt fails.
+	OnError func(error)
+}
+
+// Rotator keeps a certificate renewed before it expires. The current
+// certificate is served through GetCertificate and GetClientCertificate, which
+// can be plugged into a tls.Config so that renewals take effect on new
+// connections without a restart.
+type Rotator struct {
+	cfg RotatorConfig
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewRotator loads the certificate from CertFile and KeyFile, or issues a new
+// one if they are not set, missing or due for renewal. Call Start to begin
+// renewing it in the background.
+func NewRotator(cfg RotatorConfig) (*Rotator, error) {
+	if cfg.Sign == nil && (cfg.CACert == nil || cfg.CAKey == nil) {
+		return nil, errors.New("rotator needs either a CA or a Sign function")
+	}
+	if cfg.Sign == nil && cfg.RenewBefore > 0 {
+		dur := cfg.Duration
+		if dur == 0 {
+			dur = Duration365d
+		}
+		if dur <= cfg.RenewBefore {
+			return nil, fmt.Errorf("rotator RenewBefore %v is not shorter than Duration %v", cfg.RenewBefore, dur)
+		}
+	}
+	if cfg.MaxRetryBackoff <= 0 {
+		cfg.MaxRetryBackoff = time.Minute
+	}
+	r := &Rotator{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if cert, err := r.load(); err == nil && time.Now().Before(r.renewAt(cert.Leaf)) {
+		r.cert = cert
+		return r, nil
+	}
+	if err := r.Rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Rotator) load() (*tls.Certificate, error) {
+	if r.cfg.CertFile == "" || r.cfg.KeyFile == "" {
+		return nil, os.ErrNotExist
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// renewAt returns the time at which cert should be renewed.
+func (r *Rotator) renewAt(cert *x509.Certificate) time.Time {
+	if r.cfg.RenewBefore > 0 {
+		return cert.NotAfter.Add(-r.cfg.RenewBefore)
+	}
+	return cert.NotAfter.Add(-cert.NotAfter.Sub(cert.NotBefore) / 3)
+}
+
+// Rotate issues a new key and certificate immediately, writes them to the
+// configured files and makes them current.
+func (r *Rotator) Rotate() error {
+	key, err := NewKey(r.cfg.KeyOptions...)
+	if err != nil {
+		return err
+	}
+	var leaf *x509.Certificate
+	if r.cfg.Sign != nil {
+		leaf, err = r.cfg.Sign(r.cfg.Config, key)
+	} else {
+		leaf, err = NewSignedCertificate(r.cfg.Config, key, r.cfg.CACert, r.cfg.CAKey, r.cfg.Duration, r.cfg.CertOptions...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.cfg.KeyFile != "" && r.cfg.CertFile != "" {
+		keyPEM, err := EncodePKCS8PrivateKeyPEM(key)
+		if err != nil {
+			return err
+		}
+		if err := r.writePair(keyPEM, EncodeCertificatePEM(leaf)); err != nil {
+			return err
+		}
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	r.mu.Lock()
+	r.cert = cert
+	r.mu.Unlock()
+	if r.cfg.OnRotate != nil {
+		r.cfg.OnRotate(cert)
+	}
+	return nil
+}
+
+// writePair replaces KeyFile and CertFile with keyPEM and certPEM. The
+// certificate is renamed into place last; if that fails, the previous key is
+// restored so that the files still hold a matching pair.
+func (r *Rotator) writePair(keyPEM, certPEM []byte) error {
+	oldKey, err := ioutil.ReadFile(r.cfg.KeyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	keyTmp, err := stageFile(r.cfg.KeyFile, keyPEM, 0600)
+	if err != nil {
+		return err
+	}
+	certTmp, err := stageFile(r.cfg.CertFile, certPEM, 0644)
+	if err != nil {
+		os.Remove(keyTmp)
+		return err
+	}
+	if err := commitFile(keyTmp, r.cfg.KeyFile); err != nil {
+		os.Remove(certTmp)
+		return err
+	}
+	if err := commitFile(certTmp, r.cfg.CertFile); err != nil {
+		if oldKey != nil {
+			WriteKey(r.cfg.KeyFile, oldKey)
+		} else {
+			os.Remove(r.cfg.KeyFile)
+		}
+		return err
+	}
+	return nil
+}
+
+// Current returns the current certificate.
+func (r *Rotator) Current() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// GetCertificate returns the current certificate, for use as
+// tls.Config.GetCertificate.
+func (r *Rotator) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Current(), nil
+}
+
+// GetClientCertificate returns the current certificate, for use as
+// tls.Config.GetClientCertificate.
+func (r *Rotator) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Current(), nil
+}
+
+// Start renews the certificate in the background until Stop is called.
+func (r *Rotator) Start() {
+	go r.run()
+}
+
+// Stop stops background renewal and waits for an attempt in progress to
+// finish. It must only be called after Start. With Start, it lets a Rotator
+// be managed by anything that starts and stops services, such as a stop
+// group.
+func (r *Rotator) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+func (r *Rotator) run() {
+	defer close(r.done)
+	var backoff time.Duration
+	for {
+		wait := time.Until(r.renewAt(r.Current().Leaf))
+		if backoff > 0 {
+			wait = backoff
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-r.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		err := r.Rotate()
+		if err == nil && !time.Now().Before(r.renewAt(r.Current().Leaf)) {
+			// Retrying at once would only issue another certificate
+			// that is already due.
+			err = errors.New("renewed certificate is already due for renewal")
+		}
+		if err != nil {
+			if r.cfg.OnError != nil {
+				r.cfg.OnError(err)
+			}
+			backoff = timeutil.ExpBackoff(backoff, r.cfg.MaxRetryBackoff)
+			continue
+		}
+		backoff = 0
+	}
+}
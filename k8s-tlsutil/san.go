@@ -0,0 +1,20 @@
+package k8stlsutil
+
+import (
+	"crypto/x509"
+)
+
+// MissingSANs returns the hosts, DNS names or IP addresses, that cert is not
+// valid for, in the order given. DNS names are matched the way TLS clients
+// do, so "a.example.com" is covered by a "*.example.com" SAN but
+// "a.b.example.com" is not. Checking the result is empty before replacing a
+// serving certificate guards against dropping a name clients still use.
+func MissingSANs(cert *x509.Certificate, hosts []string) []string {
+	var missing []string
+	for _, h := range hosts {
+		if cert.VerifyHostname(h) != nil {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,67 @@
+package k8stlsutil
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"time"
+)
+
+// GenerateSelfSignedCert creates a private key and a self-signed serving
+// certificate for hosts, which may be DNS names or IP addresses, returning
+// both PEM-encoded. The first host is used as the common name. It is meant
+// for development and tests, where no CA is at hand; validFor defaults to
+// one year.
+func GenerateSelfSignedCert(hosts []string, validFor time.Duration) (keyPEM, certPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("no hosts given")
+	}
+	key, err := NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dur := Duration365d
+	if validFor != 0 {
+		dur = validFor
+	}
+	notBefore, notAfter := CertConfig{}.validity(dur)
+
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage(key.Public()),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	if err := setKeyIDs(&tmpl, key.Public(), nil); err != nil {
+		return nil, nil, err
+	}
+
+	certDERBytes, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(certDERBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return EncodePrivateKeyPEM(key), EncodeCertificatePEM(cert), nil
+}
@@ -0,0 +1,190 @@
+package k8stlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHAuthorizedKey formats pub as a line of an OpenSSH authorized_keys file,
+// with an optional comment.
+func SSHAuthorizedKey(pub crypto.PublicKey, comment string) ([]byte, error) {
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	line := bytes.TrimSuffix(ssh.MarshalAuthorizedKey(sshPub), []byte("\n"))
+	if comment != "" {
+		line = append(append(line, ' '), comment...)
+	}
+	return append(line, '\n'), nil
+}
+
+// EncodeOpenSSHPrivateKeyPEM encodes an RSA, ECDSA or Ed25519 key in the
+// unencrypted "OPENSSH PRIVATE KEY" format written by ssh-keygen, with an
+// optional comment.
+func EncodeOpenSSHPrivateKeyPEM(key crypto.Signer, comment string) ([]byte, error) {
+	sshPub, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFields []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		k.Precompute()
+		keyFields = ssh.Marshal(struct {
+			N, E, D, Iqmp, P, Q *big.Int
+		}{k.N, big.NewInt(int64(k.E)), k.D, k.Precomputed.Qinv, k.Primes[0], k.Primes[1]})
+	case *ecdsa.PrivateKey:
+		curve, err := sshCurveName(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		keyFields = ssh.Marshal(struct {
+			Curve string
+			Q     []byte
+			D     *big.Int
+		}{curve, elliptic.Marshal(k.Curve, k.X, k.Y), k.D})
+	case ed25519.PrivateKey:
+		keyFields = ssh.Marshal(struct {
+			Pub  []byte
+			Priv []byte
+		}{[]byte(k.Public().(ed25519.PublicKey)), []byte(k)})
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	checkInt := binary.BigEndian.Uint32(check[:])
+	priv := ssh.Marshal(struct {
+		Check1, Check2 uint32
+		Keytype        string
+		Rest           []byte `ssh:"rest"`
+	}{checkInt, checkInt, sshPub.Type(), keyFields})
+	priv = append(priv, ssh.Marshal(struct{ Comment string }{comment})...)
+	// Pad to the cipher block size, 8 for "none".
+	for i := byte(1); len(priv)%8 != 0; i++ {
+		priv = append(priv, i)
+	}
+
+	data := append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, sshPub.Marshal(), priv})...)
+
+	block := pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: data,
+	}
+	return pem.EncodeToMemory(&block), nil
+}
+
+func sshCurveName(c elliptic.Curve) (string, error) {
+	switch c {
+	case elliptic.P256():
+		return "nistp256", nil
+	case elliptic.P384():
+		return "nistp384", nil
+	case elliptic.P521():
+		return "nistp521", nil
+	}
+	return "", fmt.Errorf("unsupported curve %s", c.Params().Name)
+}
+
+// SSHCertConfig describes an OpenSSH certificate.
+type SSHCertConfig struct {
+	// CertType is ssh.UserCert or ssh.HostCert. Defaults to ssh.UserCert.
+	CertType uint32
+	// KeyID identifies the certificate in the server's logs.
+	KeyID string
+	// Principals are the user names, or host names for host certificates,
+	// the certificate is valid for.
+	Principals []string
+	// Duration defaults to one day. The certificate becomes valid Backdate
+	// before it is signed.
+	Duration time.Duration
+	// Permissions holds critical options and extensions. User certificates
+	// without any get the same extensions ssh-keygen grants by default.
+	Permissions ssh.Permissions
+}
+
+// NewSSHCertificate creates an OpenSSH certificate for pub, signed by caKey.
+// pub may be a crypto.PublicKey or an ssh.PublicKey, such as one parsed from
+// a user's authorized_keys line. Use ssh.MarshalAuthorizedKey to write the
+// certificate as a "-cert.pub" file, and SSHAuthorizedKey(caKey.Public(), ...)
+// for the servers' TrustedUserCAKeys or the clients' @cert-authority entries.
+func NewSSHCertificate(cfg SSHCertConfig, pub crypto.PublicKey, caKey crypto.Signer) (*ssh.Certificate, error) {
+	sshPub, ok := pub.(ssh.PublicKey)
+	if !ok {
+		var err error
+		if sshPub, err = ssh.NewPublicKey(pub); err != nil {
+			return nil, err
+		}
+	}
+	signer, err := ssh.NewSignerFromSigner(caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, err
+	}
+	certType := cfg.CertType
+	if certType == 0 {
+		certType = ssh.UserCert
+	}
+	dur := cfg.Duration
+	if dur == 0 {
+		dur = 24 * time.Hour
+	}
+	now := time.Now()
+	perms := cfg.Permissions
+	if certType == ssh.UserCert && len(perms.CriticalOptions) == 0 && len(perms.Extensions) == 0 {
+		perms.Extensions = map[string]string{
+			"permit-X11-forwarding":   "",
+			"permit-agent-forwarding": "",
+			"permit-port-forwarding":  "",
+			"permit-pty":              "",
+			"permit-user-rc":          "",
+		}
+	}
+
+	cert := &ssh.Certificate{
+		Key:             sshPub,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        certType,
+		KeyId:           cfg.KeyID,
+		ValidPrincipals: cfg.Principals,
+		ValidAfter:      uint64(now.Add(-Backdate).Unix()),
+		ValidBefore:     uint64(now.Add(dur).Unix()),
+		Permissions:     perms,
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"sync"
+)
+
+// DefaultBufferSize is the size of the buffer each copy reads into, unless
+// set with BufferSize. It matches io.Copy's.
+const DefaultBufferSize = 32 * 1024
+
+var (
+	bufferPoolsLock sync.Mutex
+	bufferPools     = map[int]*sync.Pool{}
+)
+
+// bufferPool returns the pool of buffers of the given size. Buffers are
+// shared between all CopyProgressPrinters, so that many concurrent copies
+// reuse the buffers of finished ones.
+func bufferPool(size int) *sync.Pool {
+	bufferPoolsLock.Lock()
+	defer bufferPoolsLock.Unlock()
+	p, ok := bufferPools[size]
+	if !ok {
+		p = &sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, size)
+				return &buf
+			},
+		}
+		bufferPools[size] = p
+	}
+	return p
+}
+
+// BufferSize sets the size of the buffer a copy reads into. Larger buffers
+// mean fewer reads on fast streams; buffers are pooled, so they are only
+// allocated for as many copies as run at once.
+func BufferSize(size int) CopyOption {
+	return func(cr *copyReader) {
+		if size > 0 {
+			cr.bufSize = size
+		}
+	}
+}
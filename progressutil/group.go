@@ -0,0 +1,118 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CopyGroup gathers copies under a parent bar that shows their combined
+// progress, such as the layers of an image. Create one with AddGroup and add
+// copies to it with the InGroup option.
+type CopyGroup struct {
+	name string
+	pb   *ProgressBar
+
+	lock    sync.Mutex
+	members []groupMember
+}
+
+type groupMember struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+// AddGroup adds a parent bar labeled name, drawn above the copies later added
+// to the group. Like AddCopy, it can only be called before PrintAndWait.
+func (cpp *CopyProgressPrinter) AddGroup(name string) (*CopyGroup, error) {
+	cpp.lock.Lock()
+	defer cpp.lock.Unlock()
+
+	if cpp.started {
+		return nil, ErrAlreadyStarted
+	}
+
+	g := &CopyGroup{
+		name: name,
+		pb:   cpp.pbp.AddProgressBar(),
+	}
+	g.pb.SetPrintBefore(name)
+	g.render()
+	return g, nil
+}
+
+// InGroup adds a copy to g. The copy must be added to the CopyProgressPrinter
+// g was created by.
+func InGroup(g *CopyGroup) CopyOption {
+	return func(cr *copyReader) {
+		g.lock.Lock()
+		cr.group = g
+		cr.groupIdx = len(g.members)
+		g.members = append(g.members, groupMember{total: cr.total})
+		g.render()
+		g.lock.Unlock()
+	}
+}
+
+// update records the progress of member i and redraws the group's bar.
+func (g *CopyGroup) update(i int, current, total int64, done bool) {
+	g.lock.Lock()
+	g.members[i] = groupMember{current: current, total: total, done: done}
+	g.render()
+	g.lock.Unlock()
+}
+
+// render updates the group's bar from its members. The caller must hold
+// g.lock, except during AddGroup.
+func (g *CopyGroup) render() {
+	var current, total int64
+	var done int
+	known := true
+	for _, m := range g.members {
+		current += m.current
+		total += m.total
+		if m.done {
+			done++
+		} else if m.total <= 0 {
+			known = false
+		}
+	}
+
+	n := len(g.members)
+	after := fmt.Sprintf("%d/%d, %s", done, n, ByteUnitStr(current))
+	switch {
+	case n > 0 && done == n:
+		g.pb.SetIndeterminate(false)
+		g.pb.SetCurrentProgress(1)
+	case known && total > 0:
+		progress := float64(current) / float64(total)
+		if progress > 1 {
+			progress = 1
+		}
+		after = fmt.Sprintf("%s, total %d%%", after, int(progress*100))
+		g.pb.SetIndeterminate(false)
+		g.pb.SetCurrentProgress(progress)
+	default:
+		g.pb.SetIndeterminate(true)
+		g.pb.SetCurrentProgress(0)
+	}
+	if !known {
+		total = 0
+	}
+	g.pb.SetPrintAfter(after)
+	g.pb.SetBytes(current, total)
+}
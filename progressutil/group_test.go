@@ -0,0 +1,94 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestCopyGroupProgress(t *testing.T) {
+	for i, tt := range []struct {
+		members       []groupMember
+		wantAfter     string
+		wantProgress  float64
+		indeterminate bool
+	}{
+		{
+			members:      []groupMember{{current: 0, total: 100}, {current: 0, total: 100}},
+			wantAfter:    "0/2, 0 B, total 0%",
+			wantProgress: 0,
+		},
+		{
+			members:      []groupMember{{current: 100, total: 100, done: true}, {current: 50, total: 100}},
+			wantAfter:    "1/2, 150 B, total 75%",
+			wantProgress: 0.75,
+		},
+		{
+			members:       []groupMember{{current: 100, total: 100, done: true}, {current: 50, total: 0}},
+			wantAfter:     "1/2, 150 B",
+			indeterminate: true,
+		},
+		{
+			members:      []groupMember{{current: 100, total: 100, done: true}, {current: 50, total: 50, done: true}},
+			wantAfter:    "2/2, 150 B",
+			wantProgress: 1,
+		},
+	} {
+		g := &CopyGroup{pb: (&ProgressBarPrinter{}).AddProgressBar(), members: tt.members}
+		g.render()
+		if got := g.pb.GetPrintAfter(); got != tt.wantAfter {
+			t.Errorf("case %d: want=%q got=%q", i, tt.wantAfter, got)
+		}
+		if got := g.pb.GetCurrentProgress(); got != tt.wantProgress {
+			t.Errorf("case %d: want progress=%v got=%v", i, tt.wantProgress, got)
+		}
+		if got := g.pb.GetIndeterminate(); got != tt.indeterminate {
+			t.Errorf("case %d: want indeterminate=%t got=%t", i, tt.indeterminate, got)
+		}
+	}
+}
+
+func TestCopyGroup(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+	g, err := cpp.AddGroup("image")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sampleData := []byte("this is a test!")
+	for _, name := range []string{"layer1", "layer2"} {
+		err := cpp.AddCopy(bytes.NewReader(sampleData), name, int64(len(sampleData)), ioutil.Discard, InGroup(g))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := cpp.PrintAndWait(ioutil.Discard, time.Millisecond*10, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := g.pb.GetCurrentProgress(); got != 1 {
+		t.Errorf("want progress=1 got=%v", got)
+	}
+	if want, got := "2/2, 30 B", g.pb.GetPrintAfter(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+
+	if _, err := cpp.AddGroup("late"); err != ErrAlreadyStarted {
+		t.Errorf("want=%v got=%v", ErrAlreadyStarted, err)
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"io"
 	"sync"
 	"time"
+
+	"github.com/coreos/pkg/multierror"
 )
 
 var (
@@ -31,11 +33,122 @@ type copyReader struct {
 	current int64
 	total   int64
 	pb      *ProgressBar
+	name    string
+	onDone  func(CopyResult)
+	size    func() int64
+	limiter *tokenBucket
+	rate    *rateEstimator
+	writers []io.Writer
+	retry   *retrier
+	bufSize int
+
+	group    *CopyGroup
+	groupIdx int
+}
+
+// CopyResult describes a copy that has finished, successfully or not.
+type CopyResult struct {
+	// Name is the name the copy was added with.
+	Name string
+	// Bytes is the number of bytes copied.
+	Bytes int64
+	// Duration is how long the copy ran.
+	Duration time.Duration
+	// Err is the error the copy failed with, or nil.
+	Err error
+	// Retries is the number of times the reader was reopened, see Retry.
+	Retries int
+}
+
+// CopyError is the error of a single failed copy, as returned by PrintAndWait
+// in a multierror.Error.
+type CopyError struct {
+	// Name is the name the copy was added with.
+	Name string
+	// Err is the error the copy failed with.
+	Err error
+}
+
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+// CopyOption configures a copy added with AddCopy.
+type CopyOption func(*copyReader)
+
+// OnDone registers a function to call when the copy completes or fails. It is
+// called from the copy's goroutine, before PrintAndWait learns of the result.
+func OnDone(f func(CopyResult)) CopyOption {
+	return func(cr *copyReader) {
+		cr.onDone = f
+	}
+}
+
+// SizeFunc sets a function reporting the total size of a copy added with a
+// size of 0. It is called on every read until it returns a positive size;
+// until then the copy is drawn with a spinner and a byte counter, and from
+// then on with a progress bar. This suits downloads whose length becomes
+// known after they start.
+func SizeFunc(f func() int64) CopyOption {
+	return func(cr *copyReader) {
+		cr.size = f
+	}
+}
+
+// RateLimit limits a copy to bytesPerSec bytes per second, so that large
+// downloads do not saturate the network. A limit of 0 or less has no effect.
+func RateLimit(bytesPerSec int64) CopyOption {
+	return func(cr *copyReader) {
+		if bytesPerSec > 0 {
+			cr.limiter = newTokenBucket(bytesPerSec)
+		} else {
+			cr.limiter = nil
+		}
+	}
+}
+
+// ShowRate shows the transfer rate and estimated time remaining after the
+// copy's progress. The rate is averaged with a weight that decays over window,
+// or DefaultRateWindow if window is 0, which keeps the display steady when the
+// reader delivers data in bursts.
+func ShowRate(window time.Duration) CopyOption {
+	return func(cr *copyReader) {
+		cr.rate = newRateEstimator(window)
+	}
+}
+
+// AlsoWriteTo writes the copied bytes to writers as well as to the copy's
+// destination, as io.MultiWriter does, for example to hash or upload a stream
+// while it is saved. A write error on any of them fails the copy.
+func AlsoWriteTo(writers ...io.Writer) CopyOption {
+	return func(cr *copyReader) {
+		cr.writers = append(cr.writers, writers...)
+	}
 }
 
 func (cr *copyReader) Read(p []byte) (int, error) {
+	if cr.limiter != nil {
+		p = p[:cr.limiter.limit(len(p))]
+	}
 	n, err := cr.reader.Read(p)
+	if cr.retry != nil {
+		if n > 0 {
+			cr.retry.progressed()
+		}
+		if err != nil && err != io.EOF {
+			var reader io.Reader
+			if reader, err = cr.retry.reopen(cr.current+int64(n), err); err == nil {
+				cr.reader = reader
+			}
+		}
+	}
+	if cr.limiter != nil {
+		cr.limiter.take(n)
+	}
 	cr.current += int64(n)
+	if cr.rate != nil {
+		cr.rate.update(cr.current)
+	}
 	err1 := cr.updateProgressBar()
 	if err == nil {
 		err = err1
@@ -44,8 +157,20 @@ func (cr *copyReader) Read(p []byte) (int, error) {
 }
 
 func (cr *copyReader) updateProgressBar() error {
+	if cr.total <= 0 && cr.size != nil {
+		cr.total = cr.size()
+	}
 	cr.pb.SetPrintAfter(cr.formattedProgress())
+	cr.pb.SetBytes(cr.current, cr.total)
+	if cr.group != nil {
+		cr.group.update(cr.groupIdx, cr.current, cr.total, false)
+	}
 
+	if cr.total <= 0 {
+		cr.pb.SetIndeterminate(true)
+		return nil
+	}
+	cr.pb.SetIndeterminate(false)
 	progress := float64(cr.current) / float64(cr.total)
 	if progress > 1 {
 		progress = 1
@@ -53,20 +178,69 @@ func (cr *copyReader) updateProgressBar() error {
 	return cr.pb.SetCurrentProgress(progress)
 }
 
+// finish marks the copy as complete. A copy of unknown size takes the number
+// of bytes copied as its total.
+func (cr *copyReader) finish() error {
+	if cr.total <= 0 || cr.current > cr.total {
+		cr.total = cr.current
+	}
+	cr.pb.SetIndeterminate(false)
+	cr.pb.SetPrintAfter(cr.formattedProgress())
+	cr.pb.SetBytes(cr.current, cr.total)
+	if cr.group != nil {
+		cr.group.update(cr.groupIdx, cr.current, cr.total, true)
+	}
+	return cr.pb.SetCurrentProgress(1)
+}
+
+// PrinterOption configures a CopyProgressPrinter.
+type PrinterOption func(*CopyProgressPrinter)
+
+// WithOutputFormat selects how a CopyProgressPrinter writes progress. It
+// defaults to FormatAuto.
+func WithOutputFormat(f OutputFormat) PrinterOption {
+	return func(cpp *CopyProgressPrinter) {
+		cpp.pbp.Format = f
+	}
+}
+
+// ForceTTY makes a CopyProgressPrinter draw progress bars even when not
+// printing to a terminal.
+func ForceTTY() PrinterOption {
+	return func(cpp *CopyProgressPrinter) {
+		cpp.pbp.printToTTYAlways = true
+		cpp.pbp.printToTTYNever = false
+	}
+}
+
+// ForceNoTTY makes a CopyProgressPrinter print as it does when not printing
+// to a terminal, even when it is.
+func ForceNoTTY() PrinterOption {
+	return func(cpp *CopyProgressPrinter) {
+		cpp.pbp.printToTTYAlways = false
+		cpp.pbp.printToTTYNever = true
+	}
+}
+
 // NewCopyProgressPrinter returns a new CopyProgressPrinter
-func NewCopyProgressPrinter() *CopyProgressPrinter {
-	return &CopyProgressPrinter{
-		results: make(chan error),
+func NewCopyProgressPrinter(opts ...PrinterOption) *CopyProgressPrinter {
+	cpp := &CopyProgressPrinter{
+		results: make(chan CopyResult),
 		cancel:  make(chan struct{}),
 		pbp:     &ProgressBarPrinter{PadToBeEven: true},
 	}
+	for _, opt := range opts {
+		opt(cpp)
+	}
+	return cpp
 }
 
 // CopyProgressPrinter will perform an arbitrary number of io.Copy calls, while
 // continually printing the progress of each copy.
 type CopyProgressPrinter struct {
-	results chan error
+	results chan CopyResult
 	cancel  chan struct{}
+	summary bool
 
 	// `lock` mutex protects all fields below it in CopyProgressPrinter struct
 	lock    sync.Mutex
@@ -78,10 +252,11 @@ type CopyProgressPrinter struct {
 // AddCopy adds a copy for this CopyProgressPrinter to perform. An io.Copy call
 // will be made to copy bytes from reader to dest, and name and size will be
 // used to label the progress bar and display how much progress has been made.
-// If size is 0, the total size of the reader is assumed to be unknown.
+// If size is 0, the total size of the reader is assumed to be unknown, and the
+// copy is drawn with a spinner until it completes or SizeFunc reports a size.
 // AddCopy can only be called before PrintAndWait; otherwise, ErrAlreadyStarted
 // will be returned.
-func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int64, dest io.Writer) error {
+func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int64, dest io.Writer, opts ...CopyOption) error {
 	cpp.lock.Lock()
 	defer cpp.lock.Unlock()
 
@@ -94,18 +269,49 @@ func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int6
 		current: 0,
 		total:   size,
 		pb:      cpp.pbp.AddProgressBar(),
+		name:    name,
+		bufSize: DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt(cr)
+	}
+	if len(cr.writers) > 0 {
+		dest = io.MultiWriter(append([]io.Writer{dest}, cr.writers...)...)
 	}
 	cr.pb.SetPrintBefore(name)
 	cr.pb.SetPrintAfter(cr.formattedProgress())
+	cr.pb.SetIndeterminate(size <= 0)
+	cr.pb.SetBytes(0, size)
 
 	cpp.readers = append(cpp.readers, cr)
 
 	go func() {
-		_, err := io.Copy(dest, cr)
+		start := time.Now()
+		pool := bufferPool(cr.bufSize)
+		buf := pool.Get().(*[]byte)
+		// Hide any ReadFrom method of dest, which would bypass the buffer.
+		n, err := io.CopyBuffer(struct{ io.Writer }{dest}, cr, *buf)
+		pool.Put(buf)
+		if err == nil {
+			err = cr.finish()
+		}
+		result := CopyResult{
+			Name:     cr.name,
+			Bytes:    n,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if cr.retry != nil {
+			cr.retry.close()
+			result.Retries = cr.retry.retries
+		}
+		if cr.onDone != nil {
+			cr.onDone(result)
+		}
 		select {
 		case <-cpp.cancel:
 			return
-		case cpp.results <- err:
+		case cpp.results <- result:
 			return
 		}
 	}()
@@ -115,6 +321,8 @@ func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int6
 // PrintAndWait will print the progress for each copy operation added with
 // AddCopy to printTo every printInterval. This will continue until every added
 // copy is finished, or until cancel is written to.
+// If any copies fail, the others still run to completion, and PrintAndWait
+// returns a multierror.Error holding a *CopyError for each failure.
 // PrintAndWait may only be called once; any subsequent calls will immediately
 // return ErrAlreadyStarted.  After PrintAndWait has been called, no more
 // copies may be added to the CopyProgressPrinter.
@@ -136,38 +344,58 @@ func (cpp *CopyProgressPrinter) PrintAndWait(printTo io.Writer, printInterval ti
 	defer close(cpp.cancel)
 	t := time.NewTicker(printInterval)
 	allDone := false
+	var errs multierror.Error
+	sum := Summary{Copies: n}
+	start := time.Now()
 	for i := 0; i < n; {
 		select {
 		case <-cancel:
-			return nil
+			return errs.AsError()
 		case <-t.C:
 			_, err := cpp.pbp.Print(printTo)
 			if err != nil {
 				return err
 			}
-		case err := <-cpp.results:
+		case result := <-cpp.results:
 			i++
+			sum.Bytes += result.Bytes
+			if result.Err != nil {
+				sum.Failed++
+				errs = append(errs, &CopyError{Name: result.Name, Err: result.Err})
+				continue
+			}
 			// Once completion is signaled, further on this just drains
-			// (unlikely) errors from the channel.
-			if err == nil && !allDone {
+			// the remaining results from the channel.
+			if !allDone {
+				var err error
 				allDone, err = cpp.pbp.Print(printTo)
-			}
-			if err != nil {
-				return err
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
-	return nil
+	if cpp.summary {
+		sum.Elapsed = time.Since(start)
+		if err := cpp.printSummary(printTo, sum); err != nil {
+			return err
+		}
+	}
+	return errs.AsError()
 }
 
 func (cr *copyReader) formattedProgress() string {
 	var totalStr string
-	if cr.total == 0 {
+	if cr.total <= 0 {
 		totalStr = "?"
 	} else {
 		totalStr = ByteUnitStr(cr.total)
 	}
-	return fmt.Sprintf("%s / %s", ByteUnitStr(cr.current), totalStr)
+	progress := fmt.Sprintf("%s / %s", ByteUnitStr(cr.current), totalStr)
+	if cr.rate != nil {
+		progress += cr.rate.format(cr.current, cr.total)
+	}
+	return progress
 }
 
 var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
@@ -16,10 +16,14 @@ package progressutil
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"testing"
 	"time"
+
+	"github.com/coreos/pkg/multierror"
 )
 
 type fakeReader struct {
@@ -138,3 +142,209 @@ func TestErrAlreadyStarted(t *testing.T) {
 		t.Errorf("%v\n", err)
 	}
 }
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestOnDone(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+
+	sampleData := []byte("this is a test!")
+	results := make(chan CopyResult, 2)
+	onDone := OnDone(func(r CopyResult) {
+		results <- r
+	})
+
+	fw := &bytes.Buffer{}
+	if err := cpp.AddCopy(bytes.NewReader(sampleData), "good", int64(len(sampleData)), fw, onDone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.AddCopy(errReader{}, "bad", 10, ioutil.Discard, onDone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := cpp.PrintAndWait(ioutil.Discard, time.Millisecond*10, nil); err == nil {
+		t.Errorf("expected error from PrintAndWait")
+	}
+
+	got := map[string]CopyResult{}
+	for i := 0; i < 2; i++ {
+		r := <-results
+		got[r.Name] = r
+	}
+	if r := got["good"]; r.Err != nil || r.Bytes != int64(len(sampleData)) {
+		t.Errorf("unexpected result for good copy: %+v", r)
+	}
+	if r := got["bad"]; r.Err == nil || r.Bytes != 0 {
+		t.Errorf("unexpected result for bad copy: %+v", r)
+	}
+}
+
+func TestCopyUnknownSize(t *testing.T) {
+	sampleData := []byte("this is a test!")
+
+	for i, tt := range []struct {
+		size     func() int64
+		wantSize int64
+	}{
+		{nil, 0},
+		{func() int64 { return 100 }, 100},
+	} {
+		pbp := &ProgressBarPrinter{}
+		cr := &copyReader{
+			reader: bytes.NewReader(sampleData),
+			pb:     pbp.AddProgressBar(),
+		}
+		if tt.size != nil {
+			SizeFunc(tt.size)(cr)
+		}
+
+		if _, err := cr.Read(make([]byte, 100)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cr.pb.GetIndeterminate(); got != (tt.wantSize == 0) {
+			t.Errorf("case %d: want indeterminate=%t got=%t", i, tt.wantSize == 0, got)
+		}
+		if cr.total != tt.wantSize {
+			t.Errorf("case %d: want total=%d got=%d", i, tt.wantSize, cr.total)
+		}
+
+		if err := cr.finish(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cr.pb.GetIndeterminate() || cr.pb.GetCurrentProgress() != 1 {
+			t.Errorf("case %d: finished copy should show a full bar", i)
+		}
+		if cr.total < int64(len(sampleData)) {
+			t.Errorf("case %d: total %d is less than bytes copied", i, cr.total)
+		}
+	}
+}
+
+func TestAlsoWriteTo(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+
+	sampleData := []byte("this is a test!")
+	dest, extra1, extra2 := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	err := cpp.AddCopy(bytes.NewReader(sampleData), "download", int64(len(sampleData)), dest, AlsoWriteTo(extra1), AlsoWriteTo(extra2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.PrintAndWait(ioutil.Discard, time.Millisecond*10, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, buf := range []*bytes.Buffer{dest, extra1, extra2} {
+		if !bytes.Equal(buf.Bytes(), sampleData) {
+			t.Errorf("case %d: want=%q got=%q", i, sampleData, buf.Bytes())
+		}
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+
+	sampleData := []byte("this is a test!")
+	fw := &bytes.Buffer{}
+	if err := cpp.AddCopy(errReader{}, "bad1", 10, ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.AddCopy(bytes.NewReader(sampleData), "good", int64(len(sampleData)), fw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.AddCopy(errReader{}, "bad2", 10, ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := cpp.PrintAndWait(ioutil.Discard, time.Millisecond*10, nil)
+	me, ok := err.(multierror.Error)
+	if !ok {
+		t.Fatalf("want multierror.Error, got %T: %v", err, err)
+	}
+	failed := map[string]bool{}
+	for _, err := range me {
+		ce, ok := err.(*CopyError)
+		if !ok {
+			t.Fatalf("want *CopyError, got %T: %v", err, err)
+		}
+		if ce.Err == nil || ce.Err.Error() != "read failed" {
+			t.Errorf("unexpected error for %s: %v", ce.Name, ce.Err)
+		}
+		failed[ce.Name] = true
+	}
+	if len(failed) != 2 || !failed["bad1"] || !failed["bad2"] {
+		t.Errorf("want failures of bad1 and bad2, got %v", me)
+	}
+	if !bytes.Equal(fw.Bytes(), sampleData) {
+		t.Errorf("successful copy did not complete")
+	}
+}
+
+func TestForceTTY(t *testing.T) {
+	for i, tt := range []struct {
+		opts []PrinterOption
+		want bool
+	}{
+		{nil, false},
+		{[]PrinterOption{ForceTTY()}, true},
+		{[]PrinterOption{ForceNoTTY()}, false},
+		{[]PrinterOption{ForceNoTTY(), ForceTTY()}, true},
+		{[]PrinterOption{ForceTTY(), ForceNoTTY()}, false},
+	} {
+		cpp := NewCopyProgressPrinter(tt.opts...)
+		if got := cpp.pbp.isTerminal(&bytes.Buffer{}); got != tt.want {
+			t.Errorf("case %d: want=%t got=%t", i, tt.want, got)
+		}
+	}
+}
+
+func TestBufferSize(t *testing.T) {
+	for i, size := range []int{0, 7, DefaultBufferSize} {
+		cpp := NewCopyProgressPrinter()
+		data := bytes.Repeat([]byte("0123456789"), 100)
+		fw := &bytes.Buffer{}
+		if err := cpp.AddCopy(bytes.NewReader(data), "download", int64(len(data)), fw, BufferSize(size)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := size
+		if want == 0 {
+			want = DefaultBufferSize
+		}
+		if got := cpp.readers[0].bufSize; got != want {
+			t.Errorf("case %d: want=%d got=%d", i, want, got)
+		}
+		if err := cpp.PrintAndWait(ioutil.Discard, time.Millisecond*10, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(fw.Bytes(), data) {
+			t.Errorf("case %d: copied bytes don't match", i)
+		}
+	}
+}
+
+func BenchmarkConcurrentCopies(b *testing.B) {
+	const copies = 64
+	data := make([]byte, 1<<20)
+
+	for _, size := range []int{4 * 1024, DefaultBufferSize, 1 << 20} {
+		b.Run(fmt.Sprintf("buf%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(copies * int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				cpp := NewCopyProgressPrinter()
+				for j := 0; j < copies; j++ {
+					err := cpp.AddCopy(bytes.NewReader(data), fmt.Sprintf("layer%d", j), int64(len(data)), ioutil.Discard, BufferSize(size))
+					if err != nil {
+						b.Fatalf("unexpected error: %v", err)
+					}
+				}
+				if err := cpp.PrintAndWait(ioutil.Discard, time.Second, nil); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
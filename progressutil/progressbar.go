@@ -15,6 +15,7 @@
 package progressutil
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -44,6 +45,9 @@ type ProgressBar struct {
 	printBefore     string
 	printAfter      string
 	done            bool
+	indeterminate   bool
+	current         int64
+	total           int64
 }
 
 func (pb *ProgressBar) clone() *ProgressBar {
@@ -53,6 +57,9 @@ func (pb *ProgressBar) clone() *ProgressBar {
 		printBefore:     pb.printBefore,
 		printAfter:      pb.printAfter,
 		done:            pb.done,
+		indeterminate:   pb.indeterminate,
+		current:         pb.current,
+		total:           pb.total,
 	}
 	pb.lock.Unlock()
 	return pbClone
@@ -92,6 +99,38 @@ func (pb *ProgressBar) SetDone(val bool) {
 	pb.lock.Unlock()
 }
 
+// GetIndeterminate returns whether the progress of this bar is unknown.
+func (pb *ProgressBar) GetIndeterminate() bool {
+	pb.lock.Lock()
+	val := pb.indeterminate
+	pb.lock.Unlock()
+	return val
+}
+
+// SetIndeterminate sets whether the progress of this bar is unknown. An
+// indeterminate bar is drawn with a moving spinner instead of its progress.
+func (pb *ProgressBar) SetIndeterminate(val bool) {
+	pb.lock.Lock()
+	pb.indeterminate = val
+	pb.lock.Unlock()
+}
+
+// GetBytes returns the byte counts set with SetBytes.
+func (pb *ProgressBar) GetBytes() (current, total int64) {
+	pb.lock.Lock()
+	current, total = pb.current, pb.total
+	pb.lock.Unlock()
+	return current, total
+}
+
+// SetBytes records how many of how many bytes this bar has progressed
+// through, for machine-readable output. A total of 0 means it is unknown.
+func (pb *ProgressBar) SetBytes(current, total int64) {
+	pb.lock.Lock()
+	pb.current, pb.total = current, total
+	pb.lock.Unlock()
+}
+
 // GetPrintBefore gets the text printed on the line before the progress bar.
 func (pb *ProgressBar) GetPrintBefore() string {
 	pb.lock.Lock()
@@ -122,6 +161,31 @@ func (pb *ProgressBar) SetPrintAfter(after string) {
 	pb.lock.Unlock()
 }
 
+// OutputFormat selects how a ProgressBarPrinter writes progress.
+type OutputFormat int
+
+const (
+	// FormatAuto draws progress bars on a terminal, and otherwise prints a
+	// line of text per bar until it is done.
+	FormatAuto OutputFormat = iota
+	// FormatAutoJSON draws progress bars on a terminal, and otherwise prints
+	// JSON lines as FormatJSON does.
+	FormatAutoJSON
+	// FormatJSON prints a ProgressEvent as a line of JSON per bar until it is
+	// done, for consumption by other programs.
+	FormatJSON
+)
+
+// ProgressEvent is the JSON form of a ProgressBar's state.
+type ProgressEvent struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status,omitempty"`
+	Progress float64 `json:"progress"`
+	Current  int64   `json:"current,omitempty"`
+	Total    int64   `json:"total,omitempty"`
+	Done     bool    `json:"done"`
+}
+
 // ProgressBarPrinter will print out the progress of some number of
 // ProgressBars.
 type ProgressBarPrinter struct {
@@ -135,15 +199,23 @@ type ProgressBarPrinter struct {
 	// PadToBeEven, when set to true, will make Print pad the printBefore text
 	// with trailing spaces and the printAfter text with leading spaces to make
 	// the progress bars the same length.
-	PadToBeEven         bool
+	PadToBeEven bool
+	// Format selects between progress bars and JSON output.
+	Format OutputFormat
+
 	numLinesInLastPrint int
 	progressBars        []*ProgressBar
 	maxBefore           int
 	maxAfter            int
+	// frame counts calls to Print, to animate indeterminate bars.
+	frame int
 
 	// printToTTYAlways forces this ProgressBarPrinter to always behave as if
-	// in a tty. Used for tests.
+	// in a tty. Set by ForceTTY, and used for tests.
 	printToTTYAlways bool
+	// printToTTYNever forces this ProgressBarPrinter to always behave as if
+	// not in a tty. Set by ForceNoTTY.
+	printToTTYNever bool
 }
 
 // AddProgressBar will create a new ProgressBar, register it with this
@@ -165,8 +237,9 @@ func (pbp *ProgressBarPrinter) AddProgressBar() *ProgressBar {
 // the previously printed bars.
 func (pbp *ProgressBarPrinter) Print(printTo io.Writer) (bool, error) {
 	pbp.lock.Lock()
+	originals := append([]*ProgressBar(nil), pbp.progressBars...)
 	var bars []*ProgressBar
-	for _, bar := range pbp.progressBars {
+	for _, bar := range originals {
 		bars = append(bars, bar.clone())
 	}
 	numColumns := pbp.DisplayWidth
@@ -180,7 +253,9 @@ func (pbp *ProgressBarPrinter) Print(printTo io.Writer) (bool, error) {
 		numColumns = 80
 	}
 
-	if pbp.isTerminal(printTo) {
+	terminal := pbp.isTerminal(printTo)
+	useJSON := pbp.useJSON(printTo)
+	if terminal && !useJSON {
 		moveCursorUp(printTo, pbp.numLinesInLastPrint)
 	}
 
@@ -196,16 +271,25 @@ func (pbp *ProgressBarPrinter) Print(printTo io.Writer) (bool, error) {
 	}
 
 	allDone := true
-	for _, bar := range bars {
-		if pbp.isTerminal(printTo) {
-			bar.printToTerminal(printTo, numColumns, pbp.PadToBeEven, pbp.maxBefore, pbp.maxAfter)
-		} else {
+	for i, bar := range bars {
+		switch {
+		case useJSON:
+			if err := bar.printJSON(printTo); err != nil {
+				return false, err
+			}
+		case terminal:
+			bar.printToTerminal(printTo, numColumns, pbp.PadToBeEven, pbp.maxBefore, pbp.maxAfter, pbp.frame)
+		default:
 			bar.printToNonTerminal(printTo)
 		}
+		if bar.GetDone() {
+			originals[i].SetDone(true)
+		}
 		allDone = allDone && bar.GetCurrentProgress() == 1
 	}
 
 	pbp.numLinesInLastPrint = len(bars)
+	pbp.frame++
 
 	return allDone, nil
 }
@@ -217,7 +301,7 @@ func moveCursorUp(printTo io.Writer, numLines int) {
 	}
 }
 
-func (pb *ProgressBar) printToTerminal(printTo io.Writer, numColumns int, padding bool, maxBefore, maxAfter int) {
+func (pb *ProgressBar) printToTerminal(printTo io.Writer, numColumns int, padding bool, maxBefore, maxAfter, frame int) {
 	before := pb.GetPrintBefore()
 	after := pb.GetPrintAfter()
 
@@ -228,7 +312,9 @@ func (pb *ProgressBar) printToTerminal(printTo io.Writer, numColumns int, paddin
 
 	progressBarSize := numColumns - (len(fmt.Sprintf("%s [] %s", before, after)))
 	progressBar := ""
-	if progressBarSize > 0 {
+	if progressBarSize > 0 && pb.indeterminate {
+		progressBar = fmt.Sprintf("[%s] ", spinner(progressBarSize, frame))
+	} else if progressBarSize > 0 {
 		currentProgress := int(pb.GetCurrentProgress() * float64(progressBarSize))
 		progressBar = fmt.Sprintf("[%s%s] ",
 			strings.Repeat("=", currentProgress),
@@ -242,6 +328,23 @@ func (pb *ProgressBar) printToTerminal(printTo io.Writer, numColumns int, paddin
 	fmt.Fprintf(printTo, "%s %s%s\n", before, progressBar, after)
 }
 
+// spinnerWidth is the width of the marker moving across indeterminate bars.
+const spinnerWidth = 3
+
+// spinner renders the inside of an indeterminate bar of the given size, with
+// a marker that moves one column per frame and bounces off the edges.
+func spinner(size, frame int) string {
+	if size <= spinnerWidth {
+		return strings.Repeat("=", size)
+	}
+	span := size - spinnerWidth
+	pos := frame % (2 * span)
+	if pos > span {
+		pos = 2*span - pos
+	}
+	return strings.Repeat(" ", pos) + "<=>" + strings.Repeat(" ", span-pos)
+}
+
 func (pb *ProgressBar) printToNonTerminal(printTo io.Writer) {
 	if !pb.GetDone() {
 		fmt.Fprintf(printTo, "%s %s\n", pb.printBefore, pb.printAfter)
@@ -251,11 +354,42 @@ func (pb *ProgressBar) printToNonTerminal(printTo io.Writer) {
 	}
 }
 
+func (pb *ProgressBar) printJSON(printTo io.Writer) error {
+	if pb.GetDone() {
+		return nil
+	}
+	current, total := pb.GetBytes()
+	ev := ProgressEvent{
+		Name:     pb.GetPrintBefore(),
+		Status:   pb.GetPrintAfter(),
+		Progress: pb.GetCurrentProgress(),
+		Current:  current,
+		Total:    total,
+		Done:     pb.GetCurrentProgress() == 1,
+	}
+	if pb.GetIndeterminate() {
+		ev.Progress = 0
+		ev.Done = false
+	}
+	if ev.Done {
+		pb.SetDone(true)
+	}
+	return json.NewEncoder(printTo).Encode(ev)
+}
+
+// useJSON returns whether progress written to w is formatted as JSON.
+func (pbp *ProgressBarPrinter) useJSON(w io.Writer) bool {
+	return pbp.Format == FormatJSON || (pbp.Format == FormatAutoJSON && !pbp.isTerminal(w))
+}
+
 // isTerminal returns True when w is going to a tty, and false otherwise.
 func (pbp *ProgressBarPrinter) isTerminal(w io.Writer) bool {
 	if pbp.printToTTYAlways {
 		return true
 	}
+	if pbp.printToTTYNever {
+		return false
+	}
 	if f, ok := w.(*os.File); ok {
 		return terminal.IsTerminal(int(f.Fd()))
 	}
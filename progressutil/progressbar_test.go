@@ -16,6 +16,7 @@ package progressutil
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"strings"
@@ -114,3 +115,99 @@ func renderExpectedBar(numColumns int, before string, progress float64, after st
 		strings.Repeat(" ", progressBarSize-currentProgress))
 	return fmt.Sprintf("%s %s %s", before, bar, after)
 }
+
+func TestSpinner(t *testing.T) {
+	for i, tt := range []struct {
+		size  int
+		frame int
+		want  string
+	}{
+		{6, 0, "<=>   "},
+		{6, 1, " <=>  "},
+		{6, 3, "   <=>"},
+		{6, 4, "  <=> "},
+		{6, 6, "<=>   "},
+		{2, 5, "=="},
+	} {
+		if got := spinner(tt.size, tt.frame); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestDrawIndeterminate(t *testing.T) {
+	pbp := ProgressBarPrinter{DisplayWidth: 20}
+	pbp.printToTTYAlways = true
+	pb := pbp.AddProgressBar()
+	pb.SetPrintBefore("dl")
+	pb.SetPrintAfter("1 B")
+	pb.SetIndeterminate(true)
+
+	for _, want := range []string{
+		"dl [<=>        ] 1 B\n",
+		"\033[1Adl [ <=>       ] 1 B\n",
+	} {
+		buf := &bytes.Buffer{}
+		done, err := pbp.Print(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if done {
+			t.Errorf("indeterminate bar should not be done")
+		}
+		if buf.String() != want {
+			t.Errorf("unexpected output:\nexpected:\n\n%sactual:\n\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	for i, tt := range []struct {
+		format   OutputFormat
+		tty      bool
+		wantJSON bool
+	}{
+		{FormatAuto, false, false},
+		{FormatAutoJSON, false, true},
+		{FormatAutoJSON, true, false},
+		{FormatJSON, true, true},
+	} {
+		pbp := &ProgressBarPrinter{Format: tt.format, printToTTYAlways: tt.tty}
+		pb := pbp.AddProgressBar()
+		pb.SetPrintBefore("layer")
+		pb.SetPrintAfter("50 B / 100 B")
+		pb.SetBytes(50, 100)
+		pb.SetCurrentProgress(0.5)
+
+		buf := &bytes.Buffer{}
+		if _, err := pbp.Print(buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var ev ProgressEvent
+		err := json.Unmarshal(buf.Bytes(), &ev)
+		if (err == nil) != tt.wantJSON {
+			t.Errorf("case %d: want JSON=%t got output %q", i, tt.wantJSON, buf.String())
+			continue
+		}
+		if !tt.wantJSON {
+			continue
+		}
+		want := ProgressEvent{Name: "layer", Status: "50 B / 100 B", Progress: 0.5, Current: 50, Total: 100}
+		if ev != want {
+			t.Errorf("case %d: want=%+v got=%+v", i, want, ev)
+		}
+
+		// A finished bar is reported once.
+		pb.SetBytes(100, 100)
+		pb.SetCurrentProgress(1)
+		for j, wantLines := range []int{1, 0} {
+			buf.Reset()
+			if _, err := pbp.Print(buf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.Count(buf.String(), "\n"); got != wantLines {
+				t.Errorf("case %d: print %d: want %d lines, got %q", i, j, wantLines, buf.String())
+			}
+		}
+	}
+}
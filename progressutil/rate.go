@@ -0,0 +1,102 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultRateWindow is the averaging window used by ShowRate when none is
+// given.
+const DefaultRateWindow = 5 * time.Second
+
+// minRateSample is the shortest interval over which a rate is sampled, so
+// that reads completing in quick succession are measured together.
+const minRateSample = 100 * time.Millisecond
+
+// rateEstimator computes a transfer rate as an exponentially weighted moving
+// average. Samples are weighted by the time they cover, so that the result
+// does not depend on how often the reader returns.
+type rateEstimator struct {
+	window time.Duration
+	rate   float64
+	valid  bool
+
+	lastTime  time.Time
+	lastBytes int64
+
+	now func() time.Time
+}
+
+func newRateEstimator(window time.Duration) *rateEstimator {
+	if window <= 0 {
+		window = DefaultRateWindow
+	}
+	return &rateEstimator{
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// update records that current bytes have been transferred so far.
+func (re *rateEstimator) update(current int64) {
+	now := re.now()
+	if re.lastTime.IsZero() {
+		re.lastTime, re.lastBytes = now, current
+		return
+	}
+	dt := now.Sub(re.lastTime)
+	if dt < minRateSample {
+		return
+	}
+
+	sample := float64(current-re.lastBytes) / dt.Seconds()
+	if re.valid {
+		alpha := 1 - math.Exp(-float64(dt)/float64(re.window))
+		re.rate += alpha * (sample - re.rate)
+	} else {
+		re.rate = sample
+		re.valid = true
+	}
+	re.lastTime, re.lastBytes = now, current
+}
+
+// eta returns the estimated time until total bytes have been transferred,
+// and false if it cannot be estimated yet.
+func (re *rateEstimator) eta(current, total int64) (time.Duration, bool) {
+	if !re.valid || re.rate <= 0 || total <= 0 {
+		return 0, false
+	}
+	remaining := float64(total - current)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return time.Duration(remaining / re.rate * float64(time.Second)), true
+}
+
+// format returns the rate and, if it can be estimated, the ETA, for display
+// after a copy's byte counts.
+func (re *rateEstimator) format(current, total int64) string {
+	if !re.valid {
+		return ""
+	}
+	s := fmt.Sprintf(", %s/s", ByteUnitStr(int64(re.rate)))
+	if eta, ok := re.eta(current, total); ok {
+		s += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return s
+}
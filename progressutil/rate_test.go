@@ -0,0 +1,87 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateEstimator(t *testing.T) {
+	now := time.Unix(0, 0)
+	re := newRateEstimator(time.Second)
+	re.now = func() time.Time { return now }
+
+	if got := re.format(0, 1000); got != "" {
+		t.Errorf("want no rate before the first sample, got %q", got)
+	}
+
+	var current int64
+	step := func(d time.Duration, n int64) {
+		now = now.Add(d)
+		current += n
+		re.update(current)
+	}
+
+	step(0, 0)
+	// Reads closer together than minRateSample are measured together.
+	step(10*time.Millisecond, 50)
+	if re.valid {
+		t.Errorf("rate sampled over less than %v", minRateSample)
+	}
+	step(time.Second-10*time.Millisecond, 50)
+	if re.rate != 100 {
+		t.Errorf("want=%v got=%v", 100.0, re.rate)
+	}
+
+	// A burst moves the average only part of the way.
+	step(time.Second, 1000)
+	want := 100 + (1-math.Exp(-1))*(1000-100)
+	if math.Abs(re.rate-want) > 1e-9 {
+		t.Errorf("want=%v got=%v", want, re.rate)
+	}
+	if re.rate >= 1000 {
+		t.Errorf("rate jumped to the burst rate: %v", re.rate)
+	}
+
+	// Idle time pulls it back down.
+	before := re.rate
+	step(time.Second, 0)
+	if re.rate >= before {
+		t.Errorf("rate did not decay: before=%v after=%v", before, re.rate)
+	}
+}
+
+func TestRateEstimatorFormat(t *testing.T) {
+	re := newRateEstimator(0)
+	if re.window != DefaultRateWindow {
+		t.Errorf("want=%v got=%v", DefaultRateWindow, re.window)
+	}
+	re.rate, re.valid = 1000, true
+
+	for i, tt := range []struct {
+		current, total int64
+		want           string
+	}{
+		{0, 10000, ", 1 KB/s, ETA 10s"},
+		{5000, 10000, ", 1 KB/s, ETA 5s"},
+		{5000, 0, ", 1 KB/s"},
+	} {
+		if got := re.format(tt.current, tt.total); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
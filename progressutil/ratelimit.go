@@ -0,0 +1,74 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"time"
+)
+
+// tokenBucket limits a stream to rate bytes per second, allowing bursts of up
+// to one second's worth of bytes.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newTokenBucket(bytesPerSec int64) *tokenBucket {
+	rate := float64(bytesPerSec)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  rate,
+		tokens: rate,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// limit returns how many bytes of a read of n bytes may be requested at once.
+func (tb *tokenBucket) limit(n int) int {
+	if max := int(tb.burst); n > max {
+		if max < 1 {
+			return 1
+		}
+		return max
+	}
+	return n
+}
+
+// take removes n tokens from the bucket, sleeping until the bucket has been
+// refilled if it runs short.
+func (tb *tokenBucket) take(n int) {
+	now := tb.now()
+	if !tb.last.IsZero() {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+
+	tb.tokens -= float64(n)
+	if tb.tokens < 0 {
+		wait := time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+		tb.sleep(wait)
+		tb.tokens = 0
+		tb.last = now.Add(wait)
+	}
+}
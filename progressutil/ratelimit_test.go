@@ -0,0 +1,69 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+
+	tb := newTokenBucket(100)
+	tb.now = func() time.Time { return now }
+	tb.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	cr := &copyReader{
+		reader:  bytes.NewReader(make([]byte, 1000)),
+		pb:      (&ProgressBarPrinter{}).AddProgressBar(),
+		total:   1000,
+		limiter: tb,
+	}
+	n, err := io.Copy(ioutil.Discard, cr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1000 {
+		t.Errorf("want=%d got=%d", 1000, n)
+	}
+	// The first 100 bytes are the initial burst.
+	if want := 9 * time.Second; slept != want {
+		t.Errorf("want=%v got=%v", want, slept)
+	}
+}
+
+func TestRateLimitReadSize(t *testing.T) {
+	for i, tt := range []struct {
+		rate int64
+		n    int
+		want int
+	}{
+		{100, 32 * 1024, 100},
+		{100, 10, 10},
+		{1 << 20, 32 * 1024, 32 * 1024},
+	} {
+		if got := newTokenBucket(tt.rate).limit(tt.n); got != tt.want {
+			t.Errorf("case %d: want=%d got=%d", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"io"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// RetryPolicy describes how a copy recovers from read errors.
+type RetryPolicy struct {
+	// Open returns a reader continuing the copy from offset, the number of
+	// bytes copied so far, for example by sending an HTTP request with a
+	// Range header. It is called after a read error, in place of the
+	// failed reader. Readers returned by Open that implement io.Closer are
+	// closed by the copy, when they fail or when the copy ends; the reader
+	// passed to AddCopy remains the caller's to close.
+	Open func(offset int64) (io.Reader, error)
+	// MaxAttempts is the number of times a copy is tried before its last
+	// error is returned. Attempts that make progress reset the count.
+	// Defaults to 3.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It doubles with every
+	// further retry, up to MaxBackoff. Defaults to one second.
+	Backoff time.Duration
+	// MaxBackoff defaults to one minute.
+	MaxBackoff time.Duration
+	// Retryable, if set, reports whether err is transient. By default all
+	// read errors are retried.
+	Retryable func(err error) bool
+}
+
+// retrier tracks the retries of a copy.
+type retrier struct {
+	policy   RetryPolicy
+	failures int
+	backoff  time.Duration
+	retries  int
+	// opened is the reader last returned by Open, if it needs closing.
+	opened io.Closer
+
+	sleep func(time.Duration)
+}
+
+// Retry makes a copy reopen its reader with policy.Open and carry on when a
+// read fails, instead of failing the copy. A policy without Open has no
+// effect.
+func Retry(policy RetryPolicy) CopyOption {
+	if policy.Open == nil {
+		return func(cr *copyReader) {
+			cr.retry = nil
+		}
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = time.Second
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = time.Minute
+	}
+	return func(cr *copyReader) {
+		cr.retry = &retrier{policy: policy, sleep: time.Sleep}
+	}
+}
+
+// progressed resets the retry count after a successful read.
+func (r *retrier) progressed() {
+	r.failures = 0
+	r.backoff = 0
+}
+
+// close closes the reader last returned by Open, if any.
+func (r *retrier) close() {
+	if r.opened != nil {
+		r.opened.Close()
+		r.opened = nil
+	}
+}
+
+// reopen returns a new reader continuing from offset after err, or err if the
+// copy should fail.
+func (r *retrier) reopen(offset int64, err error) (io.Reader, error) {
+	r.close()
+	for {
+		r.failures++
+		if r.failures >= r.policy.MaxAttempts || (r.policy.Retryable != nil && !r.policy.Retryable(err)) {
+			return nil, err
+		}
+		if r.backoff == 0 {
+			r.backoff = r.policy.Backoff
+		} else {
+			r.backoff = timeutil.ExpBackoff(r.backoff, r.policy.MaxBackoff)
+		}
+		r.sleep(r.backoff)
+		r.retries++
+
+		var reader io.Reader
+		if reader, err = r.policy.Open(offset); err == nil {
+			r.opened, _ = reader.(io.Closer)
+			return reader, nil
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("connection reset")
+
+// flakyReader returns errFlaky after reading limit bytes.
+type flakyReader struct {
+	r     io.Reader
+	limit int
+}
+
+func (fr *flakyReader) Read(p []byte) (int, error) {
+	if fr.limit <= 0 {
+		return 0, errFlaky
+	}
+	if len(p) > fr.limit {
+		p = p[:fr.limit]
+	}
+	n, err := fr.r.Read(p)
+	fr.limit -= n
+	return n, err
+}
+
+func TestRetry(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+
+	for i, tt := range []struct {
+		policy      RetryPolicy
+		failOpens   int
+		wantErr     error
+		wantRetries int
+		wantSleeps  []time.Duration
+	}{
+		{
+			// Every reader fails after 5 bytes, but each makes progress.
+			policy:      RetryPolicy{MaxAttempts: 2},
+			wantRetries: 4,
+			wantSleeps:  []time.Duration{time.Second, time.Second, time.Second, time.Second},
+		},
+		{
+			// Failed opens back off exponentially.
+			policy:      RetryPolicy{MaxAttempts: 4, Backoff: time.Second, MaxBackoff: 3 * time.Second},
+			failOpens:   2,
+			wantRetries: 6,
+			wantSleeps:  []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, time.Second, time.Second, time.Second},
+		},
+		{
+			policy:      RetryPolicy{MaxAttempts: 3},
+			failOpens:   10,
+			wantErr:     errFlaky,
+			wantRetries: 2,
+			wantSleeps:  []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			policy:     RetryPolicy{Retryable: func(err error) bool { return err != errFlaky }},
+			wantErr:    errFlaky,
+			wantSleeps: nil,
+		},
+	} {
+		failOpens := tt.failOpens
+		tt.policy.Open = func(offset int64) (io.Reader, error) {
+			if failOpens > 0 {
+				failOpens--
+				return nil, errFlaky
+			}
+			return &flakyReader{r: bytes.NewReader(data[offset:]), limit: 5}, nil
+		}
+
+		var sleeps []time.Duration
+		cr := &copyReader{
+			reader: &flakyReader{r: bytes.NewReader(data), limit: 5},
+			total:  int64(len(data)),
+			pb:     (&ProgressBarPrinter{}).AddProgressBar(),
+		}
+		Retry(tt.policy)(cr)
+		cr.retry.sleep = func(d time.Duration) {
+			sleeps = append(sleeps, d)
+		}
+
+		buf := &bytes.Buffer{}
+		_, err := io.Copy(buf, cr)
+		if err != tt.wantErr {
+			t.Errorf("case %d: want err=%v got=%v", i, tt.wantErr, err)
+		}
+		if err == nil && !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("case %d: want=%q got=%q", i, data, buf.Bytes())
+		}
+		if cr.retry.retries != tt.wantRetries {
+			t.Errorf("case %d: want retries=%d got=%d", i, tt.wantRetries, cr.retry.retries)
+		}
+		if len(sleeps) != len(tt.wantSleeps) {
+			t.Errorf("case %d: want sleeps=%v got=%v", i, tt.wantSleeps, sleeps)
+			continue
+		}
+		for j := range sleeps {
+			if sleeps[j] != tt.wantSleeps[j] {
+				t.Errorf("case %d: want sleeps=%v got=%v", i, tt.wantSleeps, sleeps)
+				break
+			}
+		}
+	}
+}
+
+// closeCounter counts the Close calls of a reader.
+type closeCounter struct {
+	io.Reader
+	closes int
+}
+
+func (c *closeCounter) Close() error {
+	c.closes++
+	return nil
+}
+
+func TestRetryClosesOpenedReaders(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	var opened []*closeCounter
+	policy := RetryPolicy{
+		Backoff: time.Millisecond,
+		Open: func(offset int64) (io.Reader, error) {
+			c := &closeCounter{Reader: &flakyReader{r: bytes.NewReader(data[offset:]), limit: 5}}
+			opened = append(opened, c)
+			return c, nil
+		},
+	}
+	first := &closeCounter{Reader: &flakyReader{r: bytes.NewReader(data), limit: 5}}
+
+	cpp := NewCopyProgressPrinter()
+	buf := &bytes.Buffer{}
+	if err := cpp.AddCopy(first, "download", int64(len(data)), buf, Retry(policy)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.PrintAndWait(ioutil.Discard, 10*time.Millisecond, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("want=%q got=%q", data, buf.Bytes())
+	}
+	if first.closes != 0 {
+		t.Errorf("reader passed to AddCopy closed %d times", first.closes)
+	}
+	if len(opened) != 4 {
+		t.Fatalf("want 4 opened readers, got %d", len(opened))
+	}
+	for i, c := range opened {
+		if c.closes != 1 {
+			t.Errorf("opened reader %d: want closes=1 got=%d", i, c.closes)
+		}
+	}
+}
+
+func TestRetryWithoutOpen(t *testing.T) {
+	cr := &copyReader{
+		reader: &flakyReader{r: bytes.NewReader([]byte("0123456789")), limit: 5},
+		total:  10,
+		pb:     (&ProgressBarPrinter{}).AddProgressBar(),
+	}
+	Retry(RetryPolicy{})(cr)
+	if cr.retry != nil {
+		t.Fatalf("Retry without Open enabled retries")
+	}
+	if _, err := io.Copy(ioutil.Discard, cr); err != errFlaky {
+		t.Errorf("want err=%v got=%v", errFlaky, err)
+	}
+}
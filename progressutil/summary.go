@@ -0,0 +1,72 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Summary describes a run of PrintAndWait, as printed at its end when the
+// CopyProgressPrinter was created with WithSummary.
+type Summary struct {
+	Copies int   `json:"copies"`
+	Failed int   `json:"failed"`
+	Bytes  int64 `json:"bytes"`
+	// Elapsed is the time from the call to PrintAndWait until the last copy
+	// finished, in nanoseconds in JSON.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Rate returns the average number of bytes copied per second.
+func (s Summary) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+func (s Summary) String() string {
+	noun := "copies"
+	if s.Copies == 1 {
+		noun = "copy"
+	}
+	str := fmt.Sprintf("%d %s, %s in %s (%s/s)", s.Copies, noun, ByteUnitStr(s.Bytes),
+		s.Elapsed.Round(time.Millisecond), ByteUnitStr(int64(s.Rate())))
+	if s.Failed > 0 {
+		str += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	return str
+}
+
+// WithSummary makes PrintAndWait print a Summary line once all copies have
+// finished, or a JSON object when the output format is JSON.
+func WithSummary() PrinterOption {
+	return func(cpp *CopyProgressPrinter) {
+		cpp.summary = true
+	}
+}
+
+func (cpp *CopyProgressPrinter) printSummary(printTo io.Writer, s Summary) error {
+	if cpp.pbp.useJSON(printTo) {
+		return json.NewEncoder(printTo).Encode(struct {
+			Summary Summary `json:"summary"`
+		}{s})
+	}
+	_, err := fmt.Fprintln(printTo, s)
+	return err
+}
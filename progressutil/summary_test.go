@@ -0,0 +1,82 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryString(t *testing.T) {
+	for i, tt := range []struct {
+		s    Summary
+		want string
+	}{
+		{
+			Summary{Copies: 3, Bytes: 3000, Elapsed: 2 * time.Second},
+			"3 copies, 3 KB in 2s (1.5 KB/s)",
+		},
+		{
+			Summary{Copies: 2, Failed: 1, Bytes: 10, Elapsed: 1500 * time.Microsecond},
+			"2 copies, 10 B in 2ms (6.67 KB/s), 1 failed",
+		},
+		{
+			Summary{Copies: 1},
+			"1 copy, 0 B in 0s (0 B/s)",
+		},
+	} {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestWithSummary(t *testing.T) {
+	for i, format := range []OutputFormat{FormatAuto, FormatJSON} {
+		cpp := NewCopyProgressPrinter(WithSummary(), WithOutputFormat(format))
+		sampleData := []byte("this is a test!")
+		if err := cpp.AddCopy(bytes.NewReader(sampleData), "good", int64(len(sampleData)), ioutil.Discard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := cpp.AddCopy(errReader{}, "bad", 10, ioutil.Discard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		out := &bytes.Buffer{}
+		if err := cpp.PrintAndWait(out, time.Millisecond*10, nil); err == nil {
+			t.Errorf("case %d: expected error from PrintAndWait", i)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		last := lines[len(lines)-1]
+
+		if format == FormatJSON {
+			var v struct{ Summary Summary }
+			if err := json.Unmarshal([]byte(last), &v); err != nil {
+				t.Fatalf("case %d: unexpected error: %v", i, err)
+			}
+			if v.Summary.Copies != 2 || v.Summary.Failed != 1 || v.Summary.Bytes != int64(len(sampleData)) {
+				t.Errorf("case %d: unexpected summary %+v", i, v.Summary)
+			}
+			continue
+		}
+		if !strings.HasPrefix(last, "2 copies, 15 B in ") || !strings.HasSuffix(last, ", 1 failed") {
+			t.Errorf("case %d: unexpected summary %q", i, last)
+		}
+	}
+}
@@ -14,8 +14,8 @@ COVER=${COVER:-"-cover"}
 
 source ./build.sh
 
-TESTABLE="cryptoutil flagutil timeutil netutil yamlutil httputil health multierror dlopen progressutil"
-FORMATTABLE="$TESTABLE capnslog"
+TESTABLE="cryptoutil flagutil timeutil netutil yamlutil httputil health multierror dlopen progressutil capnslog k8s-tlsutil"
+FORMATTABLE="$TESTABLE"
 
 # user has not provided PKG override
 if [ -z "$PKG" ]; then
@@ -0,0 +1,48 @@
+package yamlutil
+
+import (
+	"flag"
+)
+
+// FlagSetter is the part of a flag set this package uses. *flag.FlagSet
+// implements it; flag sets of other packages, such as pflag, can be adapted
+// with FlagSetterFuncs.
+type FlagSetter interface {
+	// Visit calls fn for each flag that has been set.
+	Visit(fn func(*flag.Flag))
+	// VisitAll calls fn for each flag.
+	VisitAll(fn func(*flag.Flag))
+	// Set sets the value of the named flag.
+	Set(name, value string) error
+}
+
+// FlagSetterFuncs implements FlagSetter with functions, which convert the
+// flags of another package to *flag.Flag. For a pflag.FlagSet:
+//
+//	toFlag := func(fn func(*flag.Flag)) func(*pflag.Flag) {
+//		return func(f *pflag.Flag) {
+//			fn(&flag.Flag{Name: f.Name, Usage: f.Usage, Value: f.Value, DefValue: f.DefValue})
+//		}
+//	}
+//	fs := yamlutil.FlagSetterFuncs{
+//		VisitFunc:    func(fn func(*flag.Flag)) { pfs.Visit(toFlag(fn)) },
+//		VisitAllFunc: func(fn func(*flag.Flag)) { pfs.VisitAll(toFlag(fn)) },
+//		SetFunc:      pfs.Set,
+//	}
+type FlagSetterFuncs struct {
+	VisitFunc    func(fn func(*flag.Flag))
+	VisitAllFunc func(fn func(*flag.Flag))
+	SetFunc      func(name, value string) error
+}
+
+func (f FlagSetterFuncs) Visit(fn func(*flag.Flag)) {
+	f.VisitFunc(fn)
+}
+
+func (f FlagSetterFuncs) VisitAll(fn func(*flag.Flag)) {
+	f.VisitAllFunc(fn)
+}
+
+func (f FlagSetterFuncs) Set(name, value string) error {
+	return f.SetFunc(name, value)
+}
@@ -0,0 +1,261 @@
+package yamlutil
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Change describes a config key whose value changed on reload.
+type Change struct {
+	Key string
+	// Old and New are the values before and after the reload. Old is empty
+	// for added keys, and New for removed ones.
+	Old, New string
+	// Removed is true if the key is no longer in the config.
+	Removed bool
+}
+
+// WatcherConfig configures a Watcher.
+type WatcherConfig struct {
+	// Path is the YAML config file.
+	Path string
+	// FlagSet holds the flags the config sets.
+	FlagSet FlagSetter
+	// Options are passed to SetFlagsFromYaml.
+	Options []Option
+	// PollInterval is how often the file is checked for changes. Defaults
+	// to five seconds. A negative interval disables polling, leaving SIGHUP
+	// and Reload.
+	PollInterval time.Duration
+	// SetFlags re-sets the flags of changed keys on reload, and resets
+	// those of removed keys to their defaults. Flags set on the command line
+	// and those named in Immutable are left alone. Flag values are not
+	// synchronized, so only flags read from OnChange, or otherwise
+	// synchronized with it, should be re-set.
+	SetFlags  bool
+	Immutable []string
+	// OnChange, if set, is called with the changed keys after every reload
+	// that changes any.
+	OnChange func([]Change)
+	// OnError, if set, is called when a reload in the background fails.
+	OnError func(error)
+}
+
+// Watcher sets flags from a YAML config and re-reads it when it changes or
+// the process receives SIGHUP, for settings that can change at runtime, such
+// as log levels.
+type Watcher struct {
+	cfg       WatcherConfig
+	opts      options
+	cmdline   map[string]struct{}
+	immutable map[string]struct{}
+
+	mu      sync.Mutex
+	conf    map[string]string
+	modTime time.Time
+	size    int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewWatcher reads the config and sets the flags from it, as
+// SetFlagsFromYaml does. Call Start to begin watching it.
+func NewWatcher(cfg WatcherConfig) (*Watcher, error) {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	w := &Watcher{
+		cfg:       cfg,
+		opts:      newOptions(cfg.Options),
+		cmdline:   map[string]struct{}{},
+		immutable: map[string]struct{}{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	cfg.FlagSet.Visit(func(f *flag.Flag) {
+		w.cmdline[f.Name] = struct{}{}
+	})
+	for _, name := range cfg.Immutable {
+		w.immutable[name] = struct{}{}
+	}
+
+	conf, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := setFlags(cfg.FlagSet, conf, w.cmdline, w.opts); err != nil {
+		return nil, err
+	}
+	w.conf = conf
+	return w, nil
+}
+
+// read reads and parses the config, recording the file's modification time
+// and size. The caller must hold w.mu, except during NewWatcher.
+func (w *Watcher) read() (map[string]string, error) {
+	fi, err := os.Stat(w.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(w.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	// An invalid config is reported once, not on every poll.
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	return parseConfig(w.cfg.FlagSet, raw, w.opts)
+}
+
+// Reload re-reads the config, re-sets flags if configured to, and calls
+// OnChange with the keys that changed. A config whose flags cannot all be set
+// is not applied: flags already re-set are restored, and the next reload is
+// compared with the config last applied.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	conf, err := w.read()
+	if err != nil {
+		return err
+	}
+	changes := diffConfig(w.conf, conf)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if w.cfg.SetFlags {
+		skip := map[string]struct{}{}
+		for name := range w.cmdline {
+			skip[name] = struct{}{}
+		}
+		for name := range w.immutable {
+			skip[name] = struct{}{}
+		}
+		changed := map[string]string{}
+		removed := map[string]bool{}
+		for _, c := range changes {
+			if c.Removed {
+				removed[c.Key] = true
+			} else {
+				changed[c.Key] = c.New
+			}
+		}
+		prev := flagValues(w.cfg.FlagSet)
+		if err := setFlags(w.cfg.FlagSet, changed, skip, w.opts); err != nil {
+			restoreFlags(w.cfg.FlagSet, prev)
+			return err
+		}
+		w.cfg.FlagSet.VisitAll(func(f *flag.Flag) {
+			if _, ok := skip[f.Name]; !ok && removed[flagKey(f.Name)] {
+				w.cfg.FlagSet.Set(f.Name, f.DefValue)
+			}
+		})
+	}
+
+	w.conf = conf
+	if w.cfg.OnChange != nil {
+		w.cfg.OnChange(changes)
+	}
+	return nil
+}
+
+// flagValues returns the current value of every flag of fs, by name.
+func flagValues(fs FlagSetter) map[string]string {
+	vals := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		vals[f.Name] = f.Value.String()
+	})
+	return vals
+}
+
+// restoreFlags sets the flags of fs whose values no longer match vals back to
+// them.
+func restoreFlags(fs FlagSetter, vals map[string]string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if val, ok := vals[f.Name]; ok && f.Value.String() != val {
+			fs.Set(f.Name, val)
+		}
+	})
+}
+
+// diffConfig returns the keys whose values differ between old and new,
+// sorted by key.
+func diffConfig(old, new map[string]string) []Change {
+	var changes []Change
+	for key, val := range new {
+		if oldVal, ok := old[key]; !ok || oldVal != val {
+			changes = append(changes, Change{Key: key, Old: oldVal, New: val})
+		}
+	}
+	for key, val := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: val, Removed: true})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// modified reports whether the file has changed since it was last read.
+func (w *Watcher) modified() bool {
+	fi, err := os.Stat(w.cfg.Path)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !fi.ModTime().Equal(w.modTime) || fi.Size() != w.size
+}
+
+// Start watches the config in the background until Stop is called.
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+// Stop stops watching the config. It must only be called after Start.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if w.cfg.PollInterval > 0 {
+		t := time.NewTicker(w.cfg.PollInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-hup:
+		case <-tick:
+			if !w.modified() {
+				continue
+			}
+		}
+		if err := w.Reload(); err != nil && w.cfg.OnError != nil {
+			w.cfg.OnError(err)
+		}
+	}
+}
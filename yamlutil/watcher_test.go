@@ -0,0 +1,184 @@
+package yamlutil
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcherReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: info\nPORT: 80\nNAME: a\nTAGS: [x]"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	logLevel := fs.String("log-level", "warning", "")
+	port := fs.Int("port", 0, "")
+	name := fs.String("name", "default", "")
+	tags := fs.String("tags", "", "")
+	fs.Set("name", "cmdline")
+
+	var changes []Change
+	w, err := NewWatcher(WatcherConfig{
+		Path:      path,
+		FlagSet:   fs,
+		SetFlags:  true,
+		Immutable: []string{"port"},
+		OnChange: func(c []Change) {
+			changes = c
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *logLevel != "info" || *port != 80 || *name != "cmdline" || *tags != "x" {
+		t.Fatalf("unexpected initial flags: log-level=%q port=%d name=%q tags=%q", *logLevel, *port, *name, *tags)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: debug\nPORT: 8080\nNAME: b\nEXTRA: 1"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Change{
+		{Key: "EXTRA", New: "1"},
+		{Key: "LOG_LEVEL", Old: "info", New: "debug"},
+		{Key: "NAME", Old: "a", New: "b"},
+		{Key: "PORT", Old: "80", New: "8080"},
+		{Key: "TAGS", Old: "x", Removed: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes=%+v got=%+v", want, changes)
+	}
+	// Immutable flags and flags set on the command line keep their values,
+	// and flags of removed keys return to their defaults.
+	if *logLevel != "debug" || *port != 80 || *name != "cmdline" || *tags != "" {
+		t.Errorf("unexpected flags after reload: log-level=%q port=%d name=%q tags=%q", *logLevel, *port, *name, *tags)
+	}
+
+	// Reloading an unchanged file reports nothing.
+	changes = nil
+	if err := w.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changes != nil {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
+
+func TestWatcherReloadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: info\nPORT: 80"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	logLevel := fs.String("log-level", "warning", "")
+	port := fs.Int("port", 0, "")
+
+	var changes []Change
+	w, err := NewWatcher(WatcherConfig{
+		Path:     path,
+		FlagSet:  fs,
+		SetFlags: true,
+		OnChange: func(c []Change) {
+			changes = c
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The valid LOG_LEVEL is not applied along with the invalid PORT.
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: debug\nPORT: http"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Reload(); err == nil {
+		t.Fatalf("expected error for an invalid port")
+	}
+	if *logLevel != "info" || *port != 80 {
+		t.Errorf("unexpected flags after failed reload: log-level=%q port=%d", *logLevel, *port)
+	}
+	if changes != nil {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	// The next reload is compared with the config last applied.
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: debug\nPORT: 80"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Change{{Key: "LOG_LEVEL", Old: "info", New: "debug"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes=%+v got=%+v", want, changes)
+	}
+	if *logLevel != "debug" || *port != 80 {
+		t.Errorf("unexpected flags after reload: log-level=%q port=%d", *logLevel, *port)
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutil")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: info"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	fs.String("log-level", "warning", "")
+
+	changed := make(chan []Change, 1)
+	w, err := NewWatcher(WatcherConfig{
+		Path:         path,
+		FlagSet:      fs,
+		PollInterval: 10 * time.Millisecond,
+		OnChange: func(c []Change) {
+			changed <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Start()
+	defer w.Stop()
+
+	if err := ioutil.WriteFile(path, []byte("LOG_LEVEL: debug"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case c := <-changed:
+		want := []Change{{Key: "LOG_LEVEL", Old: "info", New: "debug"}}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("want=%+v got=%+v", want, c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("change was not noticed")
+	}
+	// SetFlags is off.
+	if got := fs.Lookup("log-level").Value.String(); got != "info" {
+		t.Errorf("want=%q got=%q", "info", got)
+	}
+}
@@ -1,35 +1,174 @@
 package yamlutil
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"log"
+	"sort"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v1"
 )
 
+// DefaultListSeparator joins the elements of YAML lists into a flag value,
+// as expected by comma-separated slice flags such as flagutil.StringSliceFlag.
+const DefaultListSeparator = ","
+
+type options struct {
+	listSeparator string
+	strict        bool
+	aliases       map[string]string
+	logger        Logger
+	validators    []flagValidator
+	checks        []func(FlagSetter) error
+}
+
+type flagValidator struct {
+	name string
+	fn   func(value string) error
+}
+
+// Logger receives the warnings of SetFlagsFromYaml. A
+// *capnslog.PackageLogger satisfies it.
+type Logger interface {
+	Warningf(format string, args ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Warningf(format string, args ...interface{}) {
+	log.Printf("WARNING: "+format, args...)
+}
+
+// WithLogger sets the logger warnings are written to. It defaults to the
+// standard library's log package.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// Alias makes oldKey, a key used by earlier releases, set the flag named
+// flagName, with a deprecation warning. The flag's own key takes precedence
+// if both are present.
+func Alias(oldKey, flagName string) Option {
+	return func(o *options) {
+		if o.aliases == nil {
+			o.aliases = make(map[string]string)
+		}
+		o.aliases[oldKey] = flagName
+	}
+}
+
+// Option configures SetFlagsFromYaml.
+type Option func(*options)
+
+// ListSeparator sets the separator YAML lists are joined with.
+func ListSeparator(sep string) Option {
+	return func(o *options) {
+		o.listSeparator = sep
+	}
+}
+
+// Strict makes SetFlagsFromYaml fail on keys that do not belong to any flag,
+// which are otherwise ignored, so that misspelled settings are noticed.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// Validate checks the value of the named flag once the config has been
+// applied, whether it was set from the config, on the command line or left
+// at its default.
+func Validate(flagName string, fn func(value string) error) Option {
+	return func(o *options) {
+		o.validators = append(o.validators, flagValidator{flagName, fn})
+	}
+}
+
+// ValidateConfig checks the flags as a whole once the config has been
+// applied, for constraints between flags.
+func ValidateConfig(fn func(fs FlagSetter) error) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, fn)
+	}
+}
+
 // SetFlagsFromYaml goes through all registered flags in the given flagset,
 // and if they are not already set it attempts to set their values from
 // the YAML config. It will use the key REPLACE(UPPERCASE(flagname), '-', '_')
-func SetFlagsFromYaml(fs *flag.FlagSet, rawYaml []byte) (err error) {
-	conf := make(map[string]string)
-	if err = yaml.Unmarshal(rawYaml, conf); err != nil {
-		return
+// Numbers and booleans are set in their usual string form, and lists are
+// joined with DefaultListSeparator unless another is given with
+// ListSeparator. All invalid values, including those rejected by Validate and
+// ValidateConfig, are returned together in an ErrorSlice.
+func SetFlagsFromYaml(fs FlagSetter, rawYaml []byte, opts ...Option) error {
+	o := newOptions(opts)
+	conf, err := parseConfig(fs, rawYaml, o)
+	if err != nil {
+		return err
 	}
 	alreadySet := map[string]struct{}{}
 	fs.Visit(func(f *flag.Flag) {
 		alreadySet[f.Name] = struct{}{}
 	})
+	return setFlags(fs, conf, alreadySet, o)
+}
+
+func newOptions(opts []Option) options {
+	o := options{listSeparator: DefaultListSeparator, logger: stdLogger{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// parseConfig decodes a YAML config into flag values by key. A value no flag
+// could be set with, such as a map, is only an error if its key belongs to a
+// flag of fs or an alias; other keys are ignored by setFlags, unless strict.
+func parseConfig(fs FlagSetter, rawYaml []byte, o options) (map[string]string, error) {
+	raw := make(map[string]interface{})
+	if err := yaml.Unmarshal(rawYaml, raw); err != nil {
+		return nil, err
+	}
+	used := map[string]struct{}{}
+	fs.VisitAll(func(f *flag.Flag) {
+		used[flagKey(f.Name)] = struct{}{}
+	})
+	for key := range o.aliases {
+		used[key] = struct{}{}
+	}
+	conf := make(map[string]string)
+	for key, v := range raw {
+		val, err := flagValue(v, o.listSeparator)
+		if err != nil {
+			if _, ok := used[key]; ok {
+				return nil, fmt.Errorf("invalid value for %s: %v", key, err)
+			}
+			// Kept so that a Watcher still notices the key change.
+			val = fmt.Sprint(v)
+		}
+		conf[key] = val
+	}
+	applyAliases(conf, o)
+	return conf, nil
+}
 
+// setFlags sets the flags of fs from conf, except those named in skip.
+func setFlags(fs FlagSetter, conf map[string]string, skip map[string]struct{}, o options) error {
 	errs := make([]error, 0)
+	known := map[string]struct{}{}
 	fs.VisitAll(func(f *flag.Flag) {
 		if f.Name == "" {
 			return
 		}
-		if _, ok := alreadySet[f.Name]; ok {
+		tag := flagKey(f.Name)
+		known[tag] = struct{}{}
+		if _, ok := skip[f.Name]; ok {
 			return
 		}
-		tag := strings.Replace(strings.ToUpper(f.Name), "-", "_", -1)
 		val, ok := conf[tag]
 		if !ok {
 			return
@@ -38,10 +177,153 @@ func SetFlagsFromYaml(fs *flag.FlagSet, rawYaml []byte) (err error) {
 			errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", val, tag, serr))
 		}
 	})
+	if o.strict {
+		var unknown []string
+		for key := range conf {
+			if _, ok := known[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) != 0 {
+			sort.Strings(unknown)
+			errs = append(errs, fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", ")))
+		}
+	}
+	errs = append(errs, validate(fs, o)...)
 	if len(errs) != 0 {
-		err = ErrorSlice(errs)
+		return ErrorSlice(errs)
+	}
+	return nil
+}
+
+// applyAliases moves the values of deprecated keys in conf to the keys of
+// the flags they alias.
+func applyAliases(conf map[string]string, o options) {
+	var old []string
+	for key := range o.aliases {
+		if _, ok := conf[key]; ok {
+			old = append(old, key)
+		}
+	}
+	sort.Strings(old)
+	for _, key := range old {
+		newKey := flagKey(o.aliases[key])
+		if _, ok := conf[newKey]; ok {
+			o.logger.Warningf("ignoring deprecated config key %s in favor of %s", key, newKey)
+		} else {
+			o.logger.Warningf("config key %s is deprecated, use %s instead", key, newKey)
+			conf[newKey] = conf[key]
+		}
+		delete(conf, key)
+	}
+}
+
+// YamlFromFlags writes the flags of fs as a YAML config that
+// SetFlagsFromYaml reads, with each flag's usage as a comment above it. It
+// writes the flags' current values, or their defaults if defaults is true,
+// which makes a template for a new config file.
+func YamlFromFlags(fs FlagSetter, defaults bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "" || err != nil {
+			return
+		}
+		val := f.Value.String()
+		if defaults {
+			val = f.DefValue
+		}
+		var line []byte
+		if line, err = yaml.Marshal(map[string]string{flagKey(f.Name): val}); err != nil {
+			return
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		if f.Usage != "" {
+			for _, l := range strings.Split(f.Usage, "\n") {
+				buf.WriteString(strings.TrimSpace("# " + l))
+				buf.WriteByte('\n')
+			}
+		}
+		buf.Write(line)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validate runs the validators of o against fs.
+func validate(fs FlagSetter, o options) []error {
+	if len(o.validators) == 0 && len(o.checks) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+
+	var errs []error
+	for _, v := range o.validators {
+		val, ok := values[v.name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("cannot validate unknown flag %s", v.name))
+			continue
+		}
+		if err := v.fn(val); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", val, flagKey(v.name), err))
+		}
+	}
+	for _, check := range o.checks {
+		if err := check(fs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// flagKey returns the YAML key of the flag with the given name.
+func flagKey(name string) string {
+	return strings.Replace(strings.ToUpper(name), "-", "_", -1)
+}
+
+// flagValue converts a value decoded from YAML to the string a flag is set
+// with.
+func flagValue(v interface{}, sep string) (string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return scalarValue(v)
+	}
+	vals := make([]string, len(list))
+	for i, elem := range list {
+		val, err := scalarValue(elem)
+		if err != nil {
+			return "", err
+		}
+		vals[i] = val
+	}
+	return strings.Join(vals, sep), nil
+}
+
+func scalarValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
 	}
-	return
+	return "", fmt.Errorf("unsupported value of type %T", v)
 }
 
 type ErrorSlice []error
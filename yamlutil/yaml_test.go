@@ -1,7 +1,10 @@
 package yamlutil
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -78,3 +81,323 @@ func TestSetFlagsFromYamlMultiError(t *testing.T) {
 		t.Errorf("2 errors should be contained in the error, got %d errors", len(es))
 	}
 }
+
+func TestSetFlagsFromYamlTypes(t *testing.T) {
+	for i, tt := range []struct {
+		config string
+		opts   []Option
+		want   string
+	}{
+		{"A: foo", nil, "foo"},
+		{"A: 42", nil, "42"},
+		{"A: -7", nil, "-7"},
+		{"A: 9223372036854775807", nil, "9223372036854775807"},
+		{"A: 1.5", nil, "1.5"},
+		{"A: true", nil, "true"},
+		{"A: no", nil, "false"},
+		{"A:", nil, ""},
+		{"A: [foo, 2, true]", nil, "foo,2,true"},
+		{"A:\n- foo\n- bar", nil, "foo,bar"},
+		{"A: [foo, bar]", []Option{ListSeparator(" ")}, "foo bar"},
+	} {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.String("a", "unset", "")
+		if err := SetFlagsFromYaml(fs, []byte(tt.config), tt.opts...); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := fs.Lookup("a").Value.String(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestSetFlagsFromYamlTypedFlags(t *testing.T) {
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	n := fs.Int("n", 0, "")
+	b := fs.Bool("b", false, "")
+	f := fs.Float64("f", 0, "")
+	if err := SetFlagsFromYaml(fs, []byte("N: 3\nB: true\nF: 0.25")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *n != 3 || !*b || *f != 0.25 {
+		t.Errorf("want n=3 b=true f=0.25, got n=%d b=%t f=%v", *n, *b, *f)
+	}
+}
+
+func TestSetFlagsFromYamlUnsupported(t *testing.T) {
+	for i, config := range []string{
+		"A: {b: c}",
+		"A: [[b]]",
+	} {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.String("a", "", "")
+		if err := SetFlagsFromYaml(fs, []byte(config)); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestSetFlagsFromYamlUnsupportedUnusedKey(t *testing.T) {
+	config := "FOO: bar\nOTHER: {nested: 1}\nLIST: [[b]]"
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	foo := fs.String("foo", "", "")
+	if err := SetFlagsFromYaml(fs, []byte(config)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *foo != "bar" {
+		t.Errorf("want=%q got=%q", "bar", *foo)
+	}
+
+	want := "Errors: , unknown keys: LIST, OTHER"
+	if err := SetFlagsFromYaml(fs, []byte(config), Strict()); err == nil || err.Error() != want {
+		t.Errorf("want err=%q got=%v", want, err)
+	}
+	if err := SetFlagsFromYaml(fs, []byte(config), Alias("OTHER", "foo")); err == nil {
+		t.Errorf("expected error for alias with unsupported value")
+	}
+}
+
+func TestSetFlagsFromYamlStrict(t *testing.T) {
+	for i, tt := range []struct {
+		config  string
+		opts    []Option
+		wantErr string
+	}{
+		{"BIND_ADDRESS: foo\nBIND_ADRESS: bar\nPORTT: 1", nil, ""},
+		{"BIND_ADDRESS: foo\nBIND_ADRESS: bar\nPORTT: 1", []Option{Strict()}, "Errors: , unknown keys: BIND_ADRESS, PORTT"},
+		// Keys of flags set on the command line are known too.
+		{"BIND_ADDRESS: foo\nPORT: 1", []Option{Strict()}, ""},
+	} {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.String("bind-address", "", "")
+		fs.Int("port", 0, "")
+		fs.Set("port", "2")
+		err := SetFlagsFromYaml(fs, []byte(tt.config), tt.opts...)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.wantErr {
+			t.Errorf("case %d: want err=%q got=%q", i, tt.wantErr, got)
+		}
+	}
+}
+
+func TestYamlFromFlags(t *testing.T) {
+	newFlags := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.String("bind-address", "0.0.0.0", "address to listen on")
+		fs.Bool("debug", false, "enable debug logging\nvery verbose")
+		fs.String("peers", "", "")
+		fs.Int("port", 80, "port to listen on")
+		return fs
+	}
+
+	fs := newFlags()
+	fs.Set("port", "8080")
+	fs.Set("peers", "a:1,b:2")
+
+	for i, tt := range []struct {
+		defaults bool
+		want     string
+	}{
+		{
+			defaults: true,
+			want: `# address to listen on
+BIND_ADDRESS: 0.0.0.0
+
+# enable debug logging
+# very verbose
+DEBUG: "false"
+
+PEERS: ""
+
+# port to listen on
+PORT: "80"
+`,
+		},
+		{
+			defaults: false,
+			want: `# address to listen on
+BIND_ADDRESS: 0.0.0.0
+
+# enable debug logging
+# very verbose
+DEBUG: "false"
+
+PEERS: a:1,b:2
+
+# port to listen on
+PORT: "8080"
+`,
+		},
+	} {
+		out, err := YamlFromFlags(fs, tt.defaults)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(out) != tt.want {
+			t.Errorf("case %d: want:\n%s\ngot:\n%s", i, tt.want, out)
+		}
+
+		// The output reads back into the same values.
+		fs2 := newFlags()
+		if err := SetFlagsFromYaml(fs2, out, Strict()); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			want := f.Value.String()
+			if tt.defaults {
+				want = f.DefValue
+			}
+			if got := fs2.Lookup(f.Name).Value.String(); got != want {
+				t.Errorf("case %d: flag %q=%q, want %q", i, f.Name, got, want)
+			}
+		})
+	}
+}
+
+type testLogger []string
+
+func (l *testLogger) Warningf(format string, args ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+func TestSetFlagsFromYamlAlias(t *testing.T) {
+	for i, tt := range []struct {
+		config   string
+		want     string
+		warnings []string
+	}{
+		{"LISTEN_ADDR: foo", "foo", []string{"config key LISTEN_ADDR is deprecated, use BIND_ADDRESS instead"}},
+		{"LISTEN_ADDR: foo\nBIND_ADDRESS: bar", "bar", []string{"ignoring deprecated config key LISTEN_ADDR in favor of BIND_ADDRESS"}},
+		{"BIND_ADDRESS: bar", "bar", nil},
+	} {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.String("bind-address", "", "")
+		var logger testLogger
+		err := SetFlagsFromYaml(fs, []byte(tt.config), Alias("LISTEN_ADDR", "bind-address"), WithLogger(&logger), Strict())
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := fs.Lookup("bind-address").Value.String(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+		if !reflect.DeepEqual([]string(logger), tt.warnings) {
+			t.Errorf("case %d: want warnings=%q got=%q", i, tt.warnings, logger)
+		}
+	}
+}
+
+// testFlags is a flag set of another package, with its own flag type.
+type testFlags struct {
+	flags []*testFlag
+}
+
+type testFlag struct {
+	name  string
+	value string
+	set   bool
+}
+
+func (tf *testFlag) String() string { return tf.value }
+
+func (tf *testFlag) Set(v string) error {
+	tf.value, tf.set = v, true
+	return nil
+}
+
+func (tfs *testFlags) setter() FlagSetter {
+	visit := func(onlySet bool) func(fn func(*flag.Flag)) {
+		return func(fn func(*flag.Flag)) {
+			for _, f := range tfs.flags {
+				if !onlySet || f.set {
+					fn(&flag.Flag{Name: f.name, Value: f})
+				}
+			}
+		}
+	}
+	return FlagSetterFuncs{
+		VisitFunc:    visit(true),
+		VisitAllFunc: visit(false),
+		SetFunc: func(name, value string) error {
+			for _, f := range tfs.flags {
+				if f.name == name {
+					return f.Set(value)
+				}
+			}
+			return fmt.Errorf("no such flag -%s", name)
+		},
+	}
+}
+
+func TestSetFlagsFromYamlFlagSetter(t *testing.T) {
+	a := &testFlag{name: "a"}
+	b := &testFlag{name: "b"}
+	b.Set("cmdline")
+	tfs := &testFlags{flags: []*testFlag{a, b}}
+
+	if err := SetFlagsFromYaml(tfs.setter(), []byte("A: foo\nB: bar")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.value != "foo" || b.value != "cmdline" {
+		t.Errorf("want a=foo b=cmdline, got a=%s b=%s", a.value, b.value)
+	}
+}
+
+func TestSetFlagsFromYamlValidate(t *testing.T) {
+	port := func(v string) error {
+		if v == "0" {
+			return errors.New("port must be set")
+		}
+		return nil
+	}
+	for i, tt := range []struct {
+		config   string
+		wantErrs []string
+	}{
+		{"PORT: 80\nTLS_CERT: a\nTLS_KEY: b", nil},
+		{
+			"X: foo\nTLS_CERT: a",
+			[]string{
+				`invalid value "foo" for X: parse error`,
+				`invalid value "0" for PORT: port must be set`,
+				"TLS_CERT and TLS_KEY must be set together",
+			},
+		},
+	} {
+		fs := flag.NewFlagSet("testing", flag.ExitOnError)
+		fs.Int("x", 0, "")
+		fs.Int("port", 0, "")
+		fs.String("tls-cert", "", "")
+		fs.String("tls-key", "", "")
+		tls := func(fs FlagSetter) error {
+			var cert, key string
+			fs.VisitAll(func(f *flag.Flag) {
+				switch f.Name {
+				case "tls-cert":
+					cert = f.Value.String()
+				case "tls-key":
+					key = f.Value.String()
+				}
+			})
+			if (cert == "") != (key == "") {
+				return errors.New("TLS_CERT and TLS_KEY must be set together")
+			}
+			return nil
+		}
+
+		err := SetFlagsFromYaml(fs, []byte(tt.config), Validate("port", port), ValidateConfig(tls))
+		var got []string
+		if err != nil {
+			for _, e := range err.(ErrorSlice) {
+				got = append(got, e.Error())
+			}
+		}
+		if !reflect.DeepEqual(got, tt.wantErrs) {
+			t.Errorf("case %d: want errs=%q got=%q", i, tt.wantErrs, got)
+		}
+	}
+}